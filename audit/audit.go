@@ -0,0 +1,92 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audit defines audit events for access to values, and the interface
+// for sinks that receive them.
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"time"
+
+	"github.com/wealdtech/go-majordomo/internal/redact"
+)
+
+// Event is an audit event for an attempt to access a value.
+type Event struct {
+	// Time is the time at which the attempt completed.
+	Time time.Time `json:"time"`
+	// Operation is the operation attempted, for example "Fetch".
+	Operation string `json:"operation"`
+	// Scheme is the scheme of the key.
+	Scheme string `json:"scheme"`
+	// Key is the key, formatted as per the service's key format so that it
+	// does not contain credentials.
+	Key string `json:"key"`
+	// Outcome is the outcome of the attempt, for example "found" or "permission_denied".
+	Outcome string `json:"outcome"`
+	// PID is the ID of the process that made the attempt.
+	PID int `json:"pid"`
+	// Attributes are the attributes supplied by the caller in the context.
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// Sink is the interface for a sink that receives audit events.
+type Sink interface {
+	// Record records an audit event.
+	Record(ctx context.Context, event *Event) error
+}
+
+// Attributes is a context tag for attributes added to audit events, as a
+// map[string]string.  For example:
+//
+//	ctx = context.WithValue(ctx, &audit.Attributes{}, map[string]string{"request_id": id})
+type Attributes struct{}
+
+// ContextAttributes returns the attributes supplied in the context, if any.
+func ContextAttributes(ctx context.Context) map[string]string {
+	attributes, _ := ctx.Value(&Attributes{}).(map[string]string)
+	return attributes
+}
+
+// KeyFormat is the format in which keys are recorded.
+type KeyFormat int
+
+const (
+	// KeyFormatRedacted records the scheme, host and path of the key; any
+	// credentials, query parameters and fragment are removed.
+	KeyFormatRedacted KeyFormat = iota
+	// KeyFormatHashed records the hex-encoded SHA-256 hash of the key, prefixed
+	// with "sha256:".  The key is redacted as per KeyFormatRedacted before it
+	// is hashed, so the hash does not depend on any credentials it contains.
+	KeyFormatHashed
+)
+
+// FormatKey formats a key for recording.
+// Keys that hold their values, such as those for the direct confidant, are
+// always redacted, as their hashes could reveal the values.
+func FormatKey(format KeyFormat, key string) string {
+	if url, err := url.Parse(key); err == nil && redact.HoldsValue(url) {
+		return redact.URL(url)
+	}
+	redacted := redact.Key(key)
+	if format == KeyFormatHashed {
+		hash := sha256.Sum256([]byte(redacted))
+		return "sha256:" + hex.EncodeToString(hash[:])
+	}
+
+	return redacted
+}
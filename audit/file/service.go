@@ -0,0 +1,110 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	zerologger "github.com/rs/zerolog/log"
+	"github.com/wealdtech/go-majordomo/audit"
+)
+
+// Service is an audit sink that appends events to a file as JSON lines.
+// The file is created with permissions 0600 if it does not exist.
+type Service struct {
+	sync bool
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// module-wide log.
+var log zerolog.Logger
+
+// New creates a new file audit sink.
+func New(ctx context.Context, params ...Parameter) (*Service, error) {
+	parameters, err := parseAndCheckParameters(params...)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with parameters")
+	}
+
+	// Set logging.
+	log = zerologger.With().Str("service", "audit").Str("impl", "file").Logger()
+	if parameters.logLevel != log.GetLevel() {
+		log = log.Level(parameters.logLevel)
+	}
+
+	file, err := os.OpenFile(parameters.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open audit file")
+	}
+
+	s := &Service{
+		sync: parameters.sync,
+		file: file,
+	}
+
+	return s, nil
+}
+
+// Record records an audit event.
+func (s *Service) Record(ctx context.Context, event *audit.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal audit event")
+	}
+	data = append(data, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return errors.New("audit file closed")
+	}
+	// Write the event in a single call so that events are not interleaved
+	// with those from other processes appending to the same file.
+	if _, err := s.file.Write(data); err != nil {
+		return errors.Wrap(err, "failed to write audit event")
+	}
+	if s.sync {
+		if err := s.file.Sync(); err != nil {
+			return errors.Wrap(err, "failed to sync audit file")
+		}
+	}
+
+	return nil
+}
+
+// Close closes the file.  Events recorded after the sink is closed are rejected.
+func (s *Service) Close(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	if err != nil {
+		return errors.Wrap(err, "failed to close audit file")
+	}
+	log.Trace().Msg("Closed audit file")
+
+	return nil
+}
@@ -0,0 +1,94 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"github.com/wealdtech/go-majordomo/audit"
+	"github.com/wealdtech/go-majordomo/audit/file"
+)
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "audit")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = file.New(ctx, file.WithLogLevel(zerolog.Disabled))
+	require.EqualError(t, err, "problem with parameters: no path specified")
+
+	_, err = file.New(ctx, file.WithLogLevel(zerolog.Disabled), file.WithPath(filepath.Join(dir, "missing", "audit.log")))
+	require.Error(t, err)
+
+	path := filepath.Join(dir, "audit.log")
+	service, err := file.New(ctx, file.WithLogLevel(zerolog.Disabled), file.WithPath(path))
+	require.NoError(t, err)
+	require.NoError(t, service.Close(ctx))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestRecord(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "audit")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	event := &audit.Event{
+		Time:       time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Operation:  "Fetch",
+		Scheme:     "gsm",
+		Key:        "gsm://project/secret",
+		Outcome:    "found",
+		PID:        1234,
+		Attributes: map[string]string{"request_id": "abc"},
+	}
+
+	service, err := file.New(ctx, file.WithLogLevel(zerolog.Disabled), file.WithPath(path), file.WithSync(true))
+	require.NoError(t, err)
+	require.NoError(t, service.Record(ctx, event))
+	require.NoError(t, service.Close(ctx))
+
+	// Events are appended to an existing file.
+	service, err = file.New(ctx, file.WithLogLevel(zerolog.Disabled), file.WithPath(path))
+	require.NoError(t, err)
+	require.NoError(t, service.Record(ctx, &audit.Event{Operation: "Fetch", Scheme: "asm", Key: "asm://key", Outcome: "not_found"}))
+	require.NoError(t, service.Close(ctx))
+	require.NoError(t, service.Close(ctx))
+	require.EqualError(t, service.Record(ctx, event), "audit file closed")
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	require.Len(t, lines, 2)
+	require.Equal(t, `{"time":"2026-01-02T03:04:05Z","operation":"Fetch","scheme":"gsm","key":"gsm://project/secret","outcome":"found","pid":1234,"attributes":{"request_id":"abc"}}`, lines[0])
+
+	var recorded audit.Event
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &recorded))
+	require.Equal(t, "not_found", recorded.Outcome)
+	require.Nil(t, recorded.Attributes)
+}
@@ -0,0 +1,62 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/wealdtech/go-majordomo/audit"
+)
+
+// Service is an audit sink that writes events to a zerolog logger at info level.
+type Service struct {
+	log zerolog.Logger
+}
+
+// New creates a new zerolog audit sink.
+func New(ctx context.Context, params ...Parameter) (*Service, error) {
+	parameters, err := parseAndCheckParameters(params...)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with parameters")
+	}
+
+	s := &Service{
+		log: parameters.logger.With().Str("service", "audit").Str("impl", "logger").Logger(),
+	}
+
+	return s, nil
+}
+
+// Record records an audit event.
+func (s *Service) Record(ctx context.Context, event *audit.Event) error {
+	e := s.log.Info().
+		Time("accessed", event.Time).
+		Str("operation", event.Operation).
+		Str("scheme", event.Scheme).
+		Str("key", event.Key).
+		Str("outcome", event.Outcome).
+		Int("pid", event.PID)
+	if len(event.Attributes) > 0 {
+		dict := zerolog.Dict()
+		for k, v := range event.Attributes {
+			dict = dict.Str(k, v)
+		}
+		e = e.Dict("attributes", dict)
+	}
+	e.Msg("Value accessed")
+
+	return nil
+}
@@ -0,0 +1,60 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"github.com/wealdtech/go-majordomo/audit"
+	"github.com/wealdtech/go-majordomo/audit/logger"
+)
+
+func TestRecord(t *testing.T) {
+	ctx := context.Background()
+	buf := new(bytes.Buffer)
+	service, err := logger.New(ctx, logger.WithLogger(zerolog.New(buf)))
+	require.NoError(t, err)
+
+	require.NoError(t, service.Record(ctx, &audit.Event{
+		Time:       time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Operation:  "Fetch",
+		Scheme:     "gsm",
+		Key:        "gsm://project/secret",
+		Outcome:    "permission_denied",
+		PID:        1234,
+		Attributes: map[string]string{"request_id": "abc"},
+	}))
+
+	entry := make(map[string]interface{})
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	require.Equal(t, map[string]interface{}{
+		"level":      "info",
+		"service":    "audit",
+		"impl":       "logger",
+		"accessed":   "2026-01-02T03:04:05Z",
+		"operation":  "Fetch",
+		"scheme":     "gsm",
+		"key":        "gsm://project/secret",
+		"outcome":    "permission_denied",
+		"pid":        float64(1234),
+		"attributes": map[string]interface{}{"request_id": "abc"},
+		"message":    "Value accessed",
+	}, entry)
+}
@@ -0,0 +1,109 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package breaker
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	majordomo "github.com/wealdtech/go-majordomo"
+)
+
+type parameters struct {
+	logLevel         zerolog.Level
+	majordomo        majordomo.Service
+	failureThreshold int
+	openDuration     time.Duration
+	halfOpenProbes   int
+}
+
+// Parameter is the interface for service parameters.
+type Parameter interface {
+	apply(*parameters)
+}
+
+type parameterFunc func(*parameters)
+
+func (f parameterFunc) apply(p *parameters) {
+	f(p)
+}
+
+// WithLogLevel sets the log level for the module.
+func WithLogLevel(logLevel zerolog.Level) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.logLevel = logLevel
+	})
+}
+
+// WithMajordomo sets the majordomo service protected by the circuit breakers.
+func WithMajordomo(service majordomo.Service) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.majordomo = service
+	})
+}
+
+// WithFailureThreshold sets the number of consecutive failures for a scheme
+// after which its circuit opens.
+func WithFailureThreshold(failureThreshold int) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.failureThreshold = failureThreshold
+	})
+}
+
+// WithOpenDuration sets the time for which a circuit stays open before
+// allowing probes.
+func WithOpenDuration(openDuration time.Duration) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.openDuration = openDuration
+	})
+}
+
+// WithHalfOpenProbes sets the maximum number of concurrent probes allowed
+// through a half-open circuit.
+func WithHalfOpenProbes(halfOpenProbes int) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.halfOpenProbes = halfOpenProbes
+	})
+}
+
+// parseAndCheckParameters parses and checks parameters to ensure that mandatory parameters are present and correct.
+func parseAndCheckParameters(params ...Parameter) (*parameters, error) {
+	parameters := parameters{
+		logLevel:         zerolog.GlobalLevel(),
+		failureThreshold: 5,
+		openDuration:     30 * time.Second,
+		halfOpenProbes:   1,
+	}
+	for _, p := range params {
+		if params != nil {
+			p.apply(&parameters)
+		}
+	}
+
+	if parameters.majordomo == nil {
+		return nil, errors.New("no majordomo specified")
+	}
+	if parameters.failureThreshold <= 0 {
+		return nil, errors.New("failure threshold must be greater than 0")
+	}
+	if parameters.openDuration <= 0 {
+		return nil, errors.New("open duration must be greater than 0")
+	}
+	if parameters.halfOpenProbes <= 0 {
+		return nil, errors.New("half-open probes must be greater than 0")
+	}
+
+	return &parameters, nil
+}
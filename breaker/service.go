@@ -0,0 +1,265 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package breaker
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	zerologger "github.com/rs/zerolog/log"
+	majordomo "github.com/wealdtech/go-majordomo"
+)
+
+// ErrOpen is the cause of the error returned when a circuit is open.
+// The error returned is of kind majordomo.ErrUnavailable.
+var ErrOpen = errors.New("circuit breaker open")
+
+// Service is a majordomo service that protects another majordomo service
+// with a circuit breaker per scheme.
+// A circuit opens after a number of consecutive failures, after which fetches
+// for the scheme fail immediately.  Once the circuit has been open for a time
+// a limited number of probes are allowed through; the circuit closes if a
+// probe succeeds and opens again if it fails.
+// Definitive errors, such as majordomo.ErrNotFound, show that the confidant is
+// responding so are not considered failures.
+type Service struct {
+	majordomo        majordomo.Service
+	failureThreshold int
+	openDuration     time.Duration
+	halfOpenProbes   int
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+// circuit is the circuit for a scheme.
+type circuit struct {
+	state    State
+	failures int
+	openedAt time.Time
+	probes   int
+	// generation increments each time the circuit becomes half-open, to
+	// identify probes from earlier half-open periods.
+	generation uint64
+}
+
+// outcome is the outcome of a fetch, as seen by the circuit breaker.
+type outcome int
+
+const (
+	outcomeSuccess outcome = iota
+	outcomeFailure
+	outcomeIgnored
+)
+
+// definitiveErrors are errors that show the confidant is responding.
+var definitiveErrors = []error{
+	majordomo.ErrNotFound,
+	majordomo.ErrURLInvalid,
+	majordomo.ErrSchemeUnknown,
+	majordomo.ErrNotSupported,
+	majordomo.ErrValueTooLarge,
+	majordomo.ErrPermissionDenied,
+	majordomo.ErrInvalidValue,
+	majordomo.ErrNotReference,
+}
+
+// module-wide log.
+var log zerolog.Logger
+
+// New creates a new circuit breaking majordomo service.
+func New(ctx context.Context, params ...Parameter) (*Service, error) {
+	parameters, err := parseAndCheckParameters(params...)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with parameters")
+	}
+
+	// Set logging.
+	log = zerologger.With().Str("service", "majordomo").Str("impl", "breaker").Logger()
+	if parameters.logLevel != log.GetLevel() {
+		log = log.Level(parameters.logLevel)
+	}
+
+	s := &Service{
+		majordomo:        parameters.majordomo,
+		failureThreshold: parameters.failureThreshold,
+		openDuration:     parameters.openDuration,
+		halfOpenProbes:   parameters.halfOpenProbes,
+		circuits:         make(map[string]*circuit),
+	}
+
+	return s, nil
+}
+
+// Fetch fetches a value given its key.
+// If the circuit for the key's scheme is open an error of kind
+// majordomo.ErrUnavailable is returned without contacting the confidant.
+// Keys that are not references are passed through unaltered.
+func (s *Service) Fetch(ctx context.Context, key string) ([]byte, error) {
+	scheme, isReference := keyScheme(key)
+	if !isReference {
+		return s.majordomo.Fetch(ctx, key)
+	}
+
+	generation, err := s.allow(scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := s.majordomo.Fetch(ctx, key)
+	s.record(scheme, generation, fetchOutcome(ctx, err))
+
+	return value, err
+}
+
+// State returns the state of the circuit for a scheme.
+// Schemes that have not been fetched are closed.
+func (s *Service) State(scheme string) State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	circuit, exists := s.circuits[scheme]
+	if !exists {
+		return StateClosed
+	}
+	return s.currentState(circuit)
+}
+
+// States returns the state of the circuits for all schemes that have been fetched.
+func (s *Service) States() map[string]State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := make(map[string]State, len(s.circuits))
+	for scheme, circuit := range s.circuits {
+		res[scheme] = s.currentState(circuit)
+	}
+	return res
+}
+
+// currentState returns the current state of a circuit, taking in to account
+// the expiry of the open state.
+// The caller must hold the lock.
+func (s *Service) currentState(circuit *circuit) State {
+	if circuit.state == StateOpen && time.Since(circuit.openedAt) >= s.openDuration {
+		return StateHalfOpen
+	}
+	return circuit.state
+}
+
+// allow checks if a fetch for a scheme is allowed.  If the fetch is a probe of
+// a half-open circuit the generation of the circuit is returned, otherwise 0.
+func (s *Service) allow(scheme string) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, exists := s.circuits[scheme]
+	if !exists {
+		c = &circuit{}
+		s.circuits[scheme] = c
+	}
+
+	if s.currentState(c) != c.state {
+		log.Debug().Str("scheme", scheme).Msg("Circuit half-open")
+		c.state = StateHalfOpen
+		c.probes = 0
+		c.generation++
+	}
+
+	switch c.state {
+	case StateOpen:
+		return 0, majordomo.NewError(majordomo.ErrUnavailable, ErrOpen)
+	case StateHalfOpen:
+		if c.probes >= s.halfOpenProbes {
+			return 0, majordomo.NewError(majordomo.ErrUnavailable, ErrOpen)
+		}
+		c.probes++
+		return c.generation, nil
+	default:
+		return 0, nil
+	}
+}
+
+// record records the outcome of a fetch for a scheme.
+func (s *Service) record(scheme string, generation uint64, outcome outcome) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.circuits[scheme]
+	// Only probes of the current half-open period affect a half-open circuit.
+	probe := generation != 0 && generation == c.generation && c.state == StateHalfOpen
+	if probe {
+		c.probes--
+	}
+
+	switch outcome {
+	case outcomeSuccess:
+		switch {
+		case c.state == StateClosed:
+			c.failures = 0
+		case probe:
+			log.Info().Str("scheme", scheme).Msg("Circuit closed")
+			c.state = StateClosed
+			c.failures = 0
+		}
+	case outcomeFailure:
+		switch {
+		case c.state == StateClosed:
+			c.failures++
+			if c.failures >= s.failureThreshold {
+				log.Warn().Str("scheme", scheme).Int("failures", c.failures).Msg("Circuit opened")
+				c.state = StateOpen
+				c.openedAt = time.Now()
+			}
+		case probe:
+			log.Warn().Str("scheme", scheme).Msg("Probe failed; circuit reopened")
+			c.state = StateOpen
+			c.openedAt = time.Now()
+		}
+	}
+}
+
+// fetchOutcome returns the outcome of a fetch given its error.
+func fetchOutcome(ctx context.Context, err error) outcome {
+	if err == nil {
+		return outcomeSuccess
+	}
+	if errors.Is(err, context.Canceled) || ctx.Err() == context.Canceled {
+		// Cancellation by the caller says nothing about the confidant.
+		return outcomeIgnored
+	}
+	for _, definitiveErr := range definitiveErrors {
+		if errors.Is(err, definitiveErr) {
+			return outcomeSuccess
+		}
+	}
+	return outcomeFailure
+}
+
+// keyScheme returns the scheme of a key, and false if the key is not a reference.
+func keyScheme(key string) (string, bool) {
+	if !strings.Contains(key, "://") {
+		return "", false
+	}
+	url, err := url.Parse(key)
+	if err != nil || url.Scheme == "" {
+		return "", false
+	}
+	return url.Scheme, true
+}
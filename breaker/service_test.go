@@ -0,0 +1,238 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package breaker_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	majordomo "github.com/wealdtech/go-majordomo"
+	"github.com/wealdtech/go-majordomo/breaker"
+)
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := breaker.New(ctx, breaker.WithLogLevel(zerolog.Disabled))
+	require.EqualError(t, err, "problem with parameters: no majordomo specified")
+
+	_, err = breaker.New(ctx, breaker.WithLogLevel(zerolog.Disabled), breaker.WithMajordomo(&MockMajordomo{}), breaker.WithFailureThreshold(0))
+	require.EqualError(t, err, "problem with parameters: failure threshold must be greater than 0")
+
+	_, err = breaker.New(ctx, breaker.WithLogLevel(zerolog.Disabled), breaker.WithMajordomo(&MockMajordomo{}), breaker.WithOpenDuration(0))
+	require.EqualError(t, err, "problem with parameters: open duration must be greater than 0")
+
+	_, err = breaker.New(ctx, breaker.WithLogLevel(zerolog.Disabled), breaker.WithMajordomo(&MockMajordomo{}), breaker.WithHalfOpenProbes(0))
+	require.EqualError(t, err, "problem with parameters: half-open probes must be greater than 0")
+
+	_, err = breaker.New(ctx, breaker.WithLogLevel(zerolog.Disabled), breaker.WithMajordomo(&MockMajordomo{}))
+	require.NoError(t, err)
+}
+
+func TestStateString(t *testing.T) {
+	require.Equal(t, "closed", breaker.StateClosed.String())
+	require.Equal(t, "open", breaker.StateOpen.String())
+	require.Equal(t, "half-open", breaker.StateHalfOpen.String())
+	require.Equal(t, "unknown", breaker.State(99).String())
+}
+
+func TestBreaker(t *testing.T) {
+	ctx := context.Background()
+	mock := &MockMajordomo{err: majordomo.ErrUnavailable}
+	service, err := breaker.New(ctx,
+		breaker.WithLogLevel(zerolog.Disabled),
+		breaker.WithMajordomo(mock),
+		breaker.WithFailureThreshold(3),
+		breaker.WithOpenDuration(50*time.Millisecond),
+	)
+	require.NoError(t, err)
+	require.Equal(t, breaker.StateClosed, service.State("gsm"))
+
+	// Failures up to the threshold are passed through.
+	for i := 0; i < 3; i++ {
+		_, err := service.Fetch(ctx, "gsm:///key")
+		require.Equal(t, majordomo.ErrUnavailable, err)
+	}
+	require.Equal(t, 3, mock.Fetches())
+	require.Equal(t, breaker.StateOpen, service.State("gsm"))
+	require.Equal(t, map[string]breaker.State{"gsm": breaker.StateOpen}, service.States())
+
+	// Open circuit fails fast.
+	_, err = service.Fetch(ctx, "gsm:///key")
+	require.True(t, errors.Is(err, majordomo.ErrUnavailable))
+	require.True(t, errors.Is(err, breaker.ErrOpen))
+	require.Equal(t, 3, mock.Fetches())
+
+	// Other schemes are unaffected.
+	mock.SetErr(nil)
+	_, err = service.Fetch(ctx, "asm:///key")
+	require.NoError(t, err)
+	require.Equal(t, breaker.StateClosed, service.State("asm"))
+
+	// Failed probe reopens the circuit.
+	mock.SetErr(majordomo.ErrTimeout)
+	time.Sleep(60 * time.Millisecond)
+	require.Equal(t, breaker.StateHalfOpen, service.State("gsm"))
+	_, err = service.Fetch(ctx, "gsm:///key")
+	require.Equal(t, majordomo.ErrTimeout, err)
+	require.Equal(t, breaker.StateOpen, service.State("gsm"))
+
+	// Successful probe closes the circuit.
+	mock.SetErr(nil)
+	time.Sleep(60 * time.Millisecond)
+	_, err = service.Fetch(ctx, "gsm:///key")
+	require.NoError(t, err)
+	require.Equal(t, breaker.StateClosed, service.State("gsm"))
+}
+
+func TestDefinitiveErrors(t *testing.T) {
+	ctx := context.Background()
+	mock := &MockMajordomo{err: majordomo.ErrNotFound}
+	service, err := breaker.New(ctx,
+		breaker.WithLogLevel(zerolog.Disabled),
+		breaker.WithMajordomo(mock),
+		breaker.WithFailureThreshold(2),
+	)
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		_, err := service.Fetch(ctx, "gsm:///key")
+		require.Equal(t, majordomo.ErrNotFound, err)
+	}
+	require.Equal(t, breaker.StateClosed, service.State("gsm"))
+
+	// Definitive errors reset the count of consecutive failures.
+	mock.SetErr(majordomo.ErrUnavailable)
+	_, err = service.Fetch(ctx, "gsm:///key")
+	require.Error(t, err)
+	mock.SetErr(majordomo.ErrPermissionDenied)
+	_, err = service.Fetch(ctx, "gsm:///key")
+	require.Error(t, err)
+	mock.SetErr(majordomo.ErrUnavailable)
+	_, err = service.Fetch(ctx, "gsm:///key")
+	require.Error(t, err)
+	require.Equal(t, breaker.StateClosed, service.State("gsm"))
+
+	// Cancellation is not a failure.
+	mock.SetErr(context.Canceled)
+	_, err = service.Fetch(ctx, "gsm:///key")
+	require.Error(t, err)
+	require.Equal(t, breaker.StateClosed, service.State("gsm"))
+}
+
+func TestLiterals(t *testing.T) {
+	ctx := context.Background()
+	mock := &MockMajordomo{err: majordomo.ErrUnavailable}
+	service, err := breaker.New(ctx,
+		breaker.WithLogLevel(zerolog.Disabled),
+		breaker.WithMajordomo(mock),
+		breaker.WithFailureThreshold(1),
+	)
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err := service.Fetch(ctx, "literal")
+		require.Equal(t, majordomo.ErrUnavailable, err)
+	}
+	require.Equal(t, 3, mock.Fetches())
+	require.Empty(t, service.States())
+}
+
+func TestHalfOpenProbes(t *testing.T) {
+	ctx := context.Background()
+	mock := &MockMajordomo{err: majordomo.ErrUnavailable}
+	service, err := breaker.New(ctx,
+		breaker.WithLogLevel(zerolog.Disabled),
+		breaker.WithMajordomo(mock),
+		breaker.WithFailureThreshold(1),
+		breaker.WithOpenDuration(20*time.Millisecond),
+		breaker.WithHalfOpenProbes(2),
+	)
+	require.NoError(t, err)
+
+	_, err = service.Fetch(ctx, "gsm:///key")
+	require.Error(t, err)
+	require.Equal(t, breaker.StateOpen, service.State("gsm"))
+	time.Sleep(30 * time.Millisecond)
+
+	// Only the permitted number of probes reach the confidant.
+	mock.SetErr(nil)
+	mock.SetDelay(50 * time.Millisecond)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	rejected := 0
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := service.Fetch(ctx, "gsm:///key")
+			if errors.Is(err, breaker.ErrOpen) {
+				mu.Lock()
+				rejected++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, 3, rejected)
+	require.Equal(t, 3, mock.Fetches())
+	require.Equal(t, breaker.StateClosed, service.State("gsm"))
+}
+
+// MockMajordomo is a mock majordomo service that returns the configured error.
+type MockMajordomo struct {
+	mu      sync.Mutex
+	err     error
+	delay   time.Duration
+	fetches int
+}
+
+func (m *MockMajordomo) Fetch(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	m.fetches++
+	err := m.err
+	delay := m.delay
+	m.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte("value"), nil
+}
+
+func (m *MockMajordomo) SetErr(err error) {
+	m.mu.Lock()
+	m.err = err
+	m.mu.Unlock()
+}
+
+func (m *MockMajordomo) SetDelay(delay time.Duration) {
+	m.mu.Lock()
+	m.delay = delay
+	m.mu.Unlock()
+}
+
+func (m *MockMajordomo) Fetches() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.fetches
+}
@@ -0,0 +1,40 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package breaker
+
+// State is the state of a circuit.
+type State int
+
+const (
+	// StateClosed is a circuit that allows all fetches.
+	StateClosed State = iota
+	// StateOpen is a circuit that fails all fetches immediately.
+	StateOpen
+	// StateHalfOpen is a circuit that allows a limited number of probes.
+	StateHalfOpen
+)
+
+var stateStrings = [...]string{
+	"closed",
+	"open",
+	"half-open",
+}
+
+// String returns a string representation of the state.
+func (s State) String() string {
+	if s < 0 || int(s) >= len(stateStrings) {
+		return "unknown"
+	}
+	return stateStrings[s]
+}
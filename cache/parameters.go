@@ -0,0 +1,126 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	majordomo "github.com/wealdtech/go-majordomo"
+)
+
+type parameters struct {
+	logLevel    zerolog.Level
+	majordomo   majordomo.Service
+	ttl         time.Duration
+	schemeTTLs  map[string]time.Duration
+	negativeTTL time.Duration
+	maxEntries  int
+}
+
+// Parameter is the interface for service parameters.
+type Parameter interface {
+	apply(*parameters)
+}
+
+type parameterFunc func(*parameters)
+
+func (f parameterFunc) apply(p *parameters) {
+	f(p)
+}
+
+// WithLogLevel sets the log level for the module.
+func WithLogLevel(logLevel zerolog.Level) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.logLevel = logLevel
+	})
+}
+
+// WithMajordomo sets the majordomo service whose values are cached.
+func WithMajordomo(service majordomo.Service) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.majordomo = service
+	})
+}
+
+// WithTTL sets the time for which values are cached.
+// A TTL of 0 disables caching.
+func WithTTL(ttl time.Duration) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.ttl = ttl
+	})
+}
+
+// WithSchemeTTL sets the time for which values for keys with the given scheme
+// are cached, overriding the TTL set by WithTTL.  Schemes are case-insensitive.
+// A TTL of 0 disables caching for the scheme, including negative caching.
+func WithSchemeTTL(scheme string, ttl time.Duration) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.schemeTTLs[strings.ToLower(scheme)] = ttl
+	})
+}
+
+// WithNegativeTTL sets the time for which keys that are not found are cached.
+// A TTL of 0, the default, disables negative caching.
+func WithNegativeTTL(ttl time.Duration) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.negativeTTL = ttl
+	})
+}
+
+// WithMaxEntries sets the maximum number of entries in the cache.
+// When the cache is full the least recently used entry is evicted.
+func WithMaxEntries(maxEntries int) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.maxEntries = maxEntries
+	})
+}
+
+// parseAndCheckParameters parses and checks parameters to ensure that mandatory parameters are present and correct.
+func parseAndCheckParameters(params ...Parameter) (*parameters, error) {
+	parameters := parameters{
+		logLevel:   zerolog.GlobalLevel(),
+		ttl:        5 * time.Minute,
+		schemeTTLs: make(map[string]time.Duration),
+		maxEntries: 1024,
+	}
+	for _, p := range params {
+		if params != nil {
+			p.apply(&parameters)
+		}
+	}
+
+	if parameters.majordomo == nil {
+		return nil, errors.New("no majordomo specified")
+	}
+	if parameters.ttl < 0 {
+		return nil, errors.New("TTL cannot be negative")
+	}
+	for scheme, ttl := range parameters.schemeTTLs {
+		if ttl < 0 {
+			return nil, fmt.Errorf("TTL for scheme %s cannot be negative", scheme)
+		}
+	}
+	if parameters.negativeTTL < 0 {
+		return nil, errors.New("negative TTL cannot be negative")
+	}
+	if parameters.maxEntries <= 0 {
+		return nil, errors.New("max entries must be greater than 0")
+	}
+
+	return &parameters, nil
+}
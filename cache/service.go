@@ -0,0 +1,238 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"container/list"
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	zerologger "github.com/rs/zerolog/log"
+	majordomo "github.com/wealdtech/go-majordomo"
+	"github.com/wealdtech/go-majordomo/internal/memory"
+	"github.com/wealdtech/go-majordomo/policy"
+)
+
+// Service is a majordomo service that caches the values of another majordomo service.
+// Values are cached for a TTL that can be set per scheme, and the least
+// recently used values are evicted when the cache is full.  Cached values are
+// zeroed when they are evicted, expire or are invalidated.
+// Keys that are not URLs are literal values, and are not cached.
+// Concurrent fetches of the same key that is not cached result in a single
+// fetch from the underlying service.
+// Values are cached separately for each caller identity, as given by the
+// policy.Identity context value, so that a value fetched by one caller is
+// never returned to another caller that the underlying service's policy may
+// not allow to access it.
+type Service struct {
+	majordomo   majordomo.Service
+	ttl         time.Duration
+	schemeTTLs  map[string]time.Duration
+	negativeTTL time.Duration
+	maxEntries  int
+
+	mu       sync.Mutex
+	entries  map[cacheKey]*list.Element
+	lru      *list.List
+	inFlight map[cacheKey]*call
+}
+
+// cacheKey is the key for a cache entry.
+type cacheKey struct {
+	identity string
+	key      string
+}
+
+// entry is a cache entry.
+type entry struct {
+	key     cacheKey
+	value   []byte
+	err     error
+	expires time.Time
+}
+
+// call is a fetch from the underlying service that is in progress.
+type call struct {
+	done  chan struct{}
+	value []byte
+	err   error
+}
+
+// module-wide log.
+var log zerolog.Logger
+
+// New creates a new caching majordomo service.
+func New(ctx context.Context, params ...Parameter) (*Service, error) {
+	parameters, err := parseAndCheckParameters(params...)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with parameters")
+	}
+
+	// Set logging.
+	log = zerologger.With().Str("service", "majordomo").Str("impl", "cache").Logger()
+	if parameters.logLevel != log.GetLevel() {
+		log = log.Level(parameters.logLevel)
+	}
+
+	s := &Service{
+		majordomo:   parameters.majordomo,
+		ttl:         parameters.ttl,
+		schemeTTLs:  parameters.schemeTTLs,
+		negativeTTL: parameters.negativeTTL,
+		maxEntries:  parameters.maxEntries,
+		entries:     make(map[cacheKey]*list.Element),
+		lru:         list.New(),
+		inFlight:    make(map[cacheKey]*call),
+	}
+
+	return s, nil
+}
+
+// Fetch fetches a value given its key, from the cache if present.
+// The returned value is a copy, so the caller can zero it without affecting the cache.
+func (s *Service) Fetch(ctx context.Context, key string) ([]byte, error) {
+	ttl, cacheable := s.keyTTL(key)
+	if !cacheable || (ttl == 0 && s.negativeTTL == 0) {
+		return s.majordomo.Fetch(ctx, key)
+	}
+	cacheKey := cacheKey{
+		identity: policy.ContextIdentity(ctx),
+		key:      key,
+	}
+
+	s.mu.Lock()
+	if element, exists := s.entries[cacheKey]; exists {
+		entry := element.Value.(*entry)
+		if time.Now().Before(entry.expires) {
+			s.lru.MoveToFront(element)
+			value, err := memory.Copy(entry.value), entry.err
+			s.mu.Unlock()
+			log.Trace().Msg("Cache hit")
+			return value, err
+		}
+		s.removeElement(element)
+	}
+	if inFlight, exists := s.inFlight[cacheKey]; exists {
+		s.mu.Unlock()
+		select {
+		case <-inFlight.done:
+			return memory.Copy(inFlight.value), inFlight.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	inFlight := &call{
+		done: make(chan struct{}),
+	}
+	s.inFlight[cacheKey] = inFlight
+	s.mu.Unlock()
+
+	log.Trace().Msg("Cache miss")
+	value, err := s.majordomo.Fetch(ctx, key)
+	// Waiting callers copy from a private copy of the value, as this caller
+	// can zero the original as soon as it is returned.
+	inFlight.value, inFlight.err = memory.Copy(value), err
+
+	s.mu.Lock()
+	delete(s.inFlight, cacheKey)
+	switch {
+	case err == nil && ttl > 0:
+		s.add(cacheKey, memory.Copy(value), nil, ttl)
+	case errors.Is(err, majordomo.ErrNotFound) && s.negativeTTL > 0:
+		s.add(cacheKey, nil, err, s.negativeTTL)
+	}
+	s.mu.Unlock()
+	close(inFlight.done)
+
+	return value, err
+}
+
+// Invalidate removes a key from the cache, for all caller identities.
+func (s *Service) Invalidate(ctx context.Context, key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for cacheKey, element := range s.entries {
+		if cacheKey.key == key {
+			s.removeElement(element)
+		}
+	}
+}
+
+// InvalidateAll removes all keys from the cache.
+func (s *Service) InvalidateAll(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for s.lru.Len() > 0 {
+		s.removeElement(s.lru.Back())
+	}
+}
+
+// Len returns the number of entries in the cache, including those that have
+// expired but not yet been removed.
+func (s *Service) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.lru.Len()
+}
+
+// add adds an entry to the cache, evicting the least recently used entries if required.
+// The caller must hold the lock.
+func (s *Service) add(key cacheKey, value []byte, err error, ttl time.Duration) {
+	if element, exists := s.entries[key]; exists {
+		s.removeElement(element)
+	}
+	for s.lru.Len() >= s.maxEntries {
+		log.Trace().Msg("Evicting least recently used entry")
+		s.removeElement(s.lru.Back())
+	}
+	s.entries[key] = s.lru.PushFront(&entry{
+		key:     key,
+		value:   value,
+		err:     err,
+		expires: time.Now().Add(ttl),
+	})
+}
+
+// removeElement removes an element from the cache, zeroing its value.
+// The caller must hold the lock.
+func (s *Service) removeElement(element *list.Element) {
+	entry := s.lru.Remove(element).(*entry)
+	delete(s.entries, entry.key)
+	memory.Zero(entry.value)
+}
+
+// keyTTL returns the TTL for a key, and if the key can be cached.
+// Literal values, malformed keys and keys for schemes with a TTL of 0 are
+// not cached.
+func (s *Service) keyTTL(key string) (time.Duration, bool) {
+	if !strings.Contains(key, "://") {
+		return 0, false
+	}
+	url, err := url.Parse(key)
+	if err != nil || url.Scheme == "" {
+		return 0, false
+	}
+	if ttl, exists := s.schemeTTLs[url.Scheme]; exists {
+		return ttl, ttl > 0
+	}
+	return s.ttl, true
+}
@@ -0,0 +1,383 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	majordomo "github.com/wealdtech/go-majordomo"
+	"github.com/wealdtech/go-majordomo/cache"
+	"github.com/wealdtech/go-majordomo/policy"
+)
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := cache.New(ctx, cache.WithLogLevel(zerolog.Disabled))
+	require.EqualError(t, err, "problem with parameters: no majordomo specified")
+
+	_, err = cache.New(ctx, cache.WithLogLevel(zerolog.Disabled), cache.WithMajordomo(NewMockMajordomo()), cache.WithTTL(-time.Second))
+	require.EqualError(t, err, "problem with parameters: TTL cannot be negative")
+
+	_, err = cache.New(ctx, cache.WithLogLevel(zerolog.Disabled), cache.WithMajordomo(NewMockMajordomo()), cache.WithSchemeTTL("asm", -time.Second))
+	require.EqualError(t, err, "problem with parameters: TTL for scheme asm cannot be negative")
+
+	_, err = cache.New(ctx, cache.WithLogLevel(zerolog.Disabled), cache.WithMajordomo(NewMockMajordomo()), cache.WithNegativeTTL(-time.Second))
+	require.EqualError(t, err, "problem with parameters: negative TTL cannot be negative")
+
+	_, err = cache.New(ctx, cache.WithLogLevel(zerolog.Disabled), cache.WithMajordomo(NewMockMajordomo()), cache.WithMaxEntries(0))
+	require.EqualError(t, err, "problem with parameters: max entries must be greater than 0")
+
+	_, err = cache.New(ctx, cache.WithLogLevel(zerolog.Disabled), cache.WithMajordomo(NewMockMajordomo()))
+	require.NoError(t, err)
+}
+
+func TestFetch(t *testing.T) {
+	ctx := context.Background()
+	mock := NewMockMajordomo()
+	service, err := cache.New(ctx, cache.WithLogLevel(zerolog.Disabled), cache.WithMajordomo(mock))
+	require.NoError(t, err)
+
+	value, err := service.Fetch(ctx, "mock:///password")
+	require.NoError(t, err)
+	require.Equal(t, []byte("secret"), value)
+	require.Equal(t, 1, mock.Fetches("mock:///password"))
+
+	// Zeroing the returned value must not affect the cached value.
+	for i := range value {
+		value[i] = 0
+	}
+	value, err = service.Fetch(ctx, "mock:///password")
+	require.NoError(t, err)
+	require.Equal(t, []byte("secret"), value)
+	require.Equal(t, 1, mock.Fetches("mock:///password"))
+
+	// Literals are not cached.
+	value, err = service.Fetch(ctx, "literal")
+	require.NoError(t, err)
+	require.Equal(t, []byte("literal"), value)
+	_, err = service.Fetch(ctx, "literal")
+	require.NoError(t, err)
+	require.Equal(t, 2, mock.Fetches("literal"))
+	require.Equal(t, 1, service.Len())
+
+	// Errors other than not found are not cached.
+	_, err = service.Fetch(ctx, "mock:///failure")
+	require.EqualError(t, err, "confidant unavailable")
+	_, err = service.Fetch(ctx, "mock:///failure")
+	require.EqualError(t, err, "confidant unavailable")
+	require.Equal(t, 2, mock.Fetches("mock:///failure"))
+}
+
+func TestTTL(t *testing.T) {
+	ctx := context.Background()
+	mock := NewMockMajordomo()
+	service, err := cache.New(ctx,
+		cache.WithLogLevel(zerolog.Disabled),
+		cache.WithMajordomo(mock),
+		cache.WithTTL(time.Hour),
+		cache.WithSchemeTTL("short", 50*time.Millisecond),
+		cache.WithSchemeTTL("none", 0),
+		cache.WithSchemeTTL("UPPER", time.Hour),
+	)
+	require.NoError(t, err)
+
+	for _, key := range []string{"mock:///password", "short:///password", "none:///password", "upper:///password"} {
+		for i := 0; i < 2; i++ {
+			_, err := service.Fetch(ctx, key)
+			require.NoError(t, err)
+		}
+	}
+	require.Equal(t, 1, mock.Fetches("mock:///password"))
+	require.Equal(t, 1, mock.Fetches("short:///password"))
+	require.Equal(t, 2, mock.Fetches("none:///password"))
+	// Schemes are case-insensitive.
+	require.Equal(t, 1, mock.Fetches("upper:///password"))
+
+	time.Sleep(100 * time.Millisecond)
+	for _, key := range []string{"mock:///password", "short:///password"} {
+		_, err := service.Fetch(ctx, key)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 1, mock.Fetches("mock:///password"))
+	require.Equal(t, 2, mock.Fetches("short:///password"))
+}
+
+func TestNegativeCaching(t *testing.T) {
+	ctx := context.Background()
+
+	// Disabled by default.
+	mock := NewMockMajordomo()
+	service, err := cache.New(ctx, cache.WithLogLevel(zerolog.Disabled), cache.WithMajordomo(mock))
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err := service.Fetch(ctx, "mock:///missing")
+		require.True(t, errors.Is(err, majordomo.ErrNotFound))
+	}
+	require.Equal(t, 2, mock.Fetches("mock:///missing"))
+
+	mock = NewMockMajordomo()
+	service, err = cache.New(ctx,
+		cache.WithLogLevel(zerolog.Disabled),
+		cache.WithMajordomo(mock),
+		cache.WithNegativeTTL(50*time.Millisecond),
+		cache.WithSchemeTTL("none", 0),
+	)
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err := service.Fetch(ctx, "mock:///missing")
+		require.True(t, errors.Is(err, majordomo.ErrNotFound))
+		_, err = service.Fetch(ctx, "none:///missing")
+		require.True(t, errors.Is(err, majordomo.ErrNotFound))
+	}
+	require.Equal(t, 1, mock.Fetches("mock:///missing"))
+	// Schemes with caching disabled are not negatively cached either.
+	require.Equal(t, 2, mock.Fetches("none:///missing"))
+
+	time.Sleep(100 * time.Millisecond)
+	_, err = service.Fetch(ctx, "mock:///missing")
+	require.True(t, errors.Is(err, majordomo.ErrNotFound))
+	require.Equal(t, 2, mock.Fetches("mock:///missing"))
+}
+
+func TestEviction(t *testing.T) {
+	ctx := context.Background()
+	mock := NewMockMajordomo()
+	service, err := cache.New(ctx,
+		cache.WithLogLevel(zerolog.Disabled),
+		cache.WithMajordomo(mock),
+		cache.WithMaxEntries(2),
+	)
+	require.NoError(t, err)
+
+	_, err = service.Fetch(ctx, "mock:///a")
+	require.NoError(t, err)
+	_, err = service.Fetch(ctx, "mock:///b")
+	require.NoError(t, err)
+	// Use a so that b is the least recently used.
+	_, err = service.Fetch(ctx, "mock:///a")
+	require.NoError(t, err)
+	_, err = service.Fetch(ctx, "mock:///c")
+	require.NoError(t, err)
+	require.Equal(t, 2, service.Len())
+
+	_, err = service.Fetch(ctx, "mock:///a")
+	require.NoError(t, err)
+	require.Equal(t, 1, mock.Fetches("mock:///a"))
+	_, err = service.Fetch(ctx, "mock:///b")
+	require.NoError(t, err)
+	require.Equal(t, 2, mock.Fetches("mock:///b"))
+}
+
+func TestInvalidate(t *testing.T) {
+	ctx := context.Background()
+	mock := NewMockMajordomo()
+	service, err := cache.New(ctx, cache.WithLogLevel(zerolog.Disabled), cache.WithMajordomo(mock))
+	require.NoError(t, err)
+
+	_, err = service.Fetch(ctx, "mock:///a")
+	require.NoError(t, err)
+	_, err = service.Fetch(ctx, "mock:///b")
+	require.NoError(t, err)
+	require.Equal(t, 2, service.Len())
+
+	service.Invalidate(ctx, "mock:///a")
+	// Invalidating a key that is not present is not an error.
+	service.Invalidate(ctx, "mock:///unknown")
+	require.Equal(t, 1, service.Len())
+	_, err = service.Fetch(ctx, "mock:///a")
+	require.NoError(t, err)
+	require.Equal(t, 2, mock.Fetches("mock:///a"))
+	_, err = service.Fetch(ctx, "mock:///b")
+	require.NoError(t, err)
+	require.Equal(t, 1, mock.Fetches("mock:///b"))
+
+	service.InvalidateAll(ctx)
+	require.Equal(t, 0, service.Len())
+	_, err = service.Fetch(ctx, "mock:///b")
+	require.NoError(t, err)
+	require.Equal(t, 2, mock.Fetches("mock:///b"))
+}
+
+func TestIdentity(t *testing.T) {
+	ctx := context.Background()
+	mock := NewMockMajordomo()
+	service, err := cache.New(ctx, cache.WithLogLevel(zerolog.Disabled), cache.WithMajordomo(mock))
+	require.NoError(t, err)
+
+	ctxA := context.WithValue(ctx, &policy.Identity{}, "tenant-a")
+	ctxB := context.WithValue(ctx, &policy.Identity{}, "tenant-b")
+
+	value, err := service.Fetch(ctxA, "mock:///tenant-a")
+	require.NoError(t, err)
+	require.Equal(t, []byte("tenant-a secret"), value)
+	_, err = service.Fetch(ctxA, "mock:///tenant-a")
+	require.NoError(t, err)
+	require.Equal(t, 1, mock.Fetches("mock:///tenant-a"))
+
+	// Values cached for one identity are not returned to another.
+	_, err = service.Fetch(ctxB, "mock:///tenant-a")
+	require.Equal(t, majordomo.ErrPermissionDenied, err)
+	_, err = service.Fetch(ctx, "mock:///tenant-a")
+	require.Equal(t, majordomo.ErrPermissionDenied, err)
+	require.Equal(t, 3, mock.Fetches("mock:///tenant-a"))
+
+	// Values are cached per identity.
+	_, err = service.Fetch(ctx, "mock:///password")
+	require.NoError(t, err)
+	_, err = service.Fetch(ctxB, "mock:///password")
+	require.NoError(t, err)
+	require.Equal(t, 2, mock.Fetches("mock:///password"))
+	require.Equal(t, 3, service.Len())
+
+	// Invalidation removes the key for all identities.
+	service.Invalidate(ctx, "mock:///password")
+	require.Equal(t, 1, service.Len())
+}
+
+func TestConcurrent(t *testing.T) {
+	ctx := context.Background()
+	mock := NewMockMajordomo()
+	mock.delay = 50 * time.Millisecond
+	service, err := cache.New(ctx, cache.WithLogLevel(zerolog.Disabled), cache.WithMajordomo(mock))
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := service.Fetch(ctx, "mock:///password")
+			require.NoError(t, err)
+			require.Equal(t, []byte("secret"), value)
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, 1, mock.Fetches("mock:///password"))
+}
+
+func TestConcurrentZeroed(t *testing.T) {
+	ctx := context.Background()
+	mock := NewMockMajordomo()
+	mock.delay = 50 * time.Millisecond
+	service, err := cache.New(ctx, cache.WithLogLevel(zerolog.Disabled), cache.WithMajordomo(mock))
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		value, err := service.Fetch(ctx, "mock:///password")
+		require.NoError(t, err)
+		// The first caller zeroes its value as soon as it is returned.
+		for i := range value {
+			value[i] = 0
+		}
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	// Waiting callers are unaffected.
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := service.Fetch(ctx, "mock:///password")
+			require.NoError(t, err)
+			require.Equal(t, []byte("secret"), value)
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, 1, mock.Fetches("mock:///password"))
+
+	// Nor is the cached value.
+	value, err := service.Fetch(ctx, "mock:///password")
+	require.NoError(t, err)
+	require.Equal(t, []byte("secret"), value)
+}
+
+func TestConcurrentCancelled(t *testing.T) {
+	ctx := context.Background()
+	mock := NewMockMajordomo()
+	mock.delay = 200 * time.Millisecond
+	service, err := cache.New(ctx, cache.WithLogLevel(zerolog.Disabled), cache.WithMajordomo(mock))
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, err := service.Fetch(ctx, "mock:///password")
+		require.NoError(t, err)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// A waiting fetch returns when its own context is done.
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = service.Fetch(waitCtx, "mock:///password")
+	require.Equal(t, context.DeadlineExceeded, err)
+	wg.Wait()
+}
+
+// MockMajordomo is a mock majordomo service that counts fetches.
+// Keys are returned as their values, other than a few special cases.
+type MockMajordomo struct {
+	delay   time.Duration
+	mu      sync.Mutex
+	fetches map[string]int
+}
+
+func NewMockMajordomo() *MockMajordomo {
+	return &MockMajordomo{
+		fetches: make(map[string]int),
+	}
+}
+
+func (m *MockMajordomo) Fetch(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	m.fetches[key]++
+	m.mu.Unlock()
+	if m.delay > 0 {
+		time.Sleep(m.delay)
+	}
+
+	switch {
+	case strings.HasSuffix(key, ":///password"):
+		return []byte("secret"), nil
+	case strings.HasSuffix(key, ":///tenant-a"):
+		if policy.ContextIdentity(ctx) != "tenant-a" {
+			return nil, majordomo.ErrPermissionDenied
+		}
+		return []byte("tenant-a secret"), nil
+	case strings.HasSuffix(key, ":///missing"):
+		return nil, majordomo.ErrNotFound
+	case strings.HasSuffix(key, ":///failure"):
+		return nil, majordomo.ErrUnavailable
+	default:
+		return []byte(key), nil
+	}
+}
+
+func (m *MockMajordomo) Fetches(key string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.fetches[key]
+}
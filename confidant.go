@@ -15,13 +15,115 @@ package majordomo
 
 import (
 	"context"
+	"io"
 	"net/url"
 )
 
 // Confidant is the interface for services that hold secrets.
+// Confidants are compared with each other when registered with a service, so
+// must be of a comparable type; in general they are pointers to structs.
 type Confidant interface {
 	// SupportedURLSchemes provides the list of schemes supported by this confidant.
 	SupportedURLSchemes(ctx context.Context) ([]string, error)
 	// Fetch fetches a value given its URL.
+	// The returned value belongs to the caller, which can modify or zero it, so
+	// the confidant must not retain it.  The same applies to values returned by
+	// FetchWithMetadata and FetchBatch.
 	Fetch(ctx context.Context, url *url.URL) ([]byte, error)
 }
+
+// WritableConfidant is the interface for confidants that can also store and delete secrets.
+type WritableConfidant interface {
+	Confidant
+	// Store stores a value given its URL, overwriting any existing value.
+	Store(ctx context.Context, url *url.URL, value []byte) error
+	// Delete deletes a value given its URL.
+	Delete(ctx context.Context, url *url.URL) error
+}
+
+// Lister is the interface for confidants that can list the keys they hold.
+type Lister interface {
+	Confidant
+	// List lists the keys that match the prefix URL.
+	// Keys are returned as URLs that can be passed to Fetch.
+	// pageToken is empty for the first page, and the token returned from the
+	// previous call for subsequent pages.  The returned token is empty when
+	// there are no further pages.
+	// pageSize is the maximum number of keys to return; 0 uses the confidant's default.
+	List(ctx context.Context, prefix *url.URL, pageToken string, pageSize int) ([]string, string, error)
+}
+
+// MetadataFetcher is the interface for confidants that can provide metadata
+// about a secret alongside its value.
+type MetadataFetcher interface {
+	Confidant
+	// FetchWithMetadata fetches a value and its metadata given its URL.
+	FetchWithMetadata(ctx context.Context, url *url.URL) ([]byte, *Metadata, error)
+}
+
+// WatchEvent is an event sent when a watched value changes.
+type WatchEvent struct {
+	// Value is the new value.
+	Value []byte
+	// Err is the error encountered when obtaining the value, if any.
+	Err error
+}
+
+// Watcher is the interface for confidants that can watch values for changes.
+type Watcher interface {
+	Confidant
+	// Watch watches a value given its URL.
+	// An event containing the current value is sent immediately, followed by
+	// an event each time the value changes.  The returned channel is closed
+	// when the context is cancelled.
+	// The confidant can retain the values of events to detect changes, so the
+	// receiver must not modify them.
+	Watch(ctx context.Context, url *url.URL) (<-chan *WatchEvent, error)
+}
+
+// StreamFetcher is the interface for confidants that can stream values
+// rather than holding them in memory.
+type StreamFetcher interface {
+	Confidant
+	// FetchStream fetches a value given its URL, returning a reader for the value.
+	// The reader returns ErrValueTooLarge if the value is larger than maxSize bytes;
+	// a maxSize of 0 means that there is no limit.
+	// The caller must close the reader.
+	FetchStream(ctx context.Context, url *url.URL, maxSize int64) (io.ReadCloser, error)
+}
+
+// FetchResult is the result of fetching a single value as part of a batch.
+type FetchResult struct {
+	// Value is the value.
+	Value []byte
+	// Err is the error encountered when fetching the value, if any.
+	Err error
+}
+
+// BatchFetcher is the interface for confidants that can fetch multiple values in a single operation.
+type BatchFetcher interface {
+	Confidant
+	// FetchBatch fetches values given their URLs.
+	// The returned results are in the same order as the supplied URLs.
+	// An error is returned only if the batch as a whole failed; errors for
+	// individual values are returned in their results.
+	FetchBatch(ctx context.Context, urls []*url.URL) ([]*FetchResult, error)
+}
+
+// Pinger is the interface for confidants that can check their own health.
+type Pinger interface {
+	Confidant
+	// Ping checks that the confidant is able to provide values, returning an
+	// error if not.  Checks should be cheap enough to run frequently.
+	Ping(ctx context.Context) error
+}
+
+// Closer is the interface for confidants that hold resources, such as
+// connections to remote services, that should be released when the confidant
+// is no longer required.
+type Closer interface {
+	Confidant
+	// Close releases the resources held by the confidant.
+	// The confidant should not be used after it has been closed.
+	Close(ctx context.Context) error
+}
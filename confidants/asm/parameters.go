@@ -14,14 +14,20 @@
 package asm
 
 import (
+	"time"
+
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type parameters struct {
-	logLevel    zerolog.Level
-	credentials *credentials.Credentials
-	region      string
+	logLevel       zerolog.Level
+	credentials    *credentials.Credentials
+	region         string
+	watchInterval  time.Duration
+	tracerProvider trace.TracerProvider
 }
 
 // Parameter is the interface for service parameters.
@@ -56,10 +62,26 @@ func WithRegion(region string) Parameter {
 	})
 }
 
+// WithWatchInterval sets the interval at which values are polled when watched.
+func WithWatchInterval(interval time.Duration) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.watchInterval = interval
+	})
+}
+
+// WithTracerProvider sets the OpenTelemetry tracer provider used to create
+// spans.  If not supplied no spans are created.
+func WithTracerProvider(provider trace.TracerProvider) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.tracerProvider = provider
+	})
+}
+
 // parseAndCheckParameters parses and checks parameters to ensure that mandatory parameters are present and correct.
 func parseAndCheckParameters(params ...Parameter) (*parameters, error) {
 	parameters := parameters{
-		logLevel: zerolog.GlobalLevel(),
+		logLevel:      zerolog.GlobalLevel(),
+		watchInterval: time.Minute,
 	}
 	for _, p := range params {
 		if params != nil {
@@ -67,5 +89,9 @@ func parseAndCheckParameters(params ...Parameter) (*parameters, error) {
 		}
 	}
 
+	if parameters.watchInterval <= 0 {
+		return nil, errors.New("watch interval must be greater than 0")
+	}
+
 	return &parameters, nil
 }
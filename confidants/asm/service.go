@@ -16,18 +16,30 @@ package asm
 import (
 	"context"
 	"encoding/base64"
+	"fmt"
+	"net/http"
 	"net/url"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/sts"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 	zerologger "github.com/rs/zerolog/log"
 	"github.com/wealdtech/go-majordomo"
+	"github.com/wealdtech/go-majordomo/internal/memory"
+	"github.com/wealdtech/go-majordomo/internal/poll"
+	"github.com/wealdtech/go-majordomo/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Service returns values from Amazon secrets manager.
@@ -37,11 +49,44 @@ import (
 // region can also be supplied at creation time if preferred.
 // If both are supplied URLs are of the form "asm:///secret".
 // Any provision of ID and secret or of region will override the defaults.
+// By default the current version of the secret is returned.  A specific
+// version can be selected with the "stage" query parameter, for example
+// "asm://region/secret?stage=AWSPREVIOUS", or the "version" query parameter,
+// for example "asm://region/secret?version=<version ID>".
+// Clients are created on demand for each combination of region and
+// credentials, and held for the lifetime of the service along with their
+// connections, which are released when the service is closed.
 type Service struct {
-	credentials *credentials.Credentials
-	region      string
+	credentials   *credentials.Credentials
+	region        string
+	watchInterval time.Duration
+	httpClient    *http.Client
+	tracer        trace.Tracer
+
+	clientsMu sync.Mutex
+	clients   map[clientKey]*secretsmanager.SecretsManager
+	closed    bool
+}
+
+// clientKey is the key for a pooled secrets manager client.
+type clientKey struct {
+	region string
+	id     string
+	secret string
 }
 
+// maxBatchSize is the maximum number of secrets that can be fetched in a single batch.
+const maxBatchSize = 20
+
+// stageRegex matches valid version stages.
+var stageRegex = regexp.MustCompile(`^[a-zA-Z0-9_+=.@-]{1,256}$`)
+
+// versionRegex matches valid version IDs.
+var versionRegex = regexp.MustCompile(`^[a-zA-Z0-9-]{32,64}$`)
+
+// errClosed is returned when a request is made after the service has been closed.
+var errClosed = majordomo.NewError(majordomo.ErrUnavailable, errors.New("confidant closed"))
+
 // module-wide log.
 var log zerolog.Logger
 
@@ -59,8 +104,14 @@ func New(ctx context.Context, params ...Parameter) (*Service, error) {
 	}
 
 	s := &Service{
-		credentials: parameters.credentials,
-		region:      parameters.region,
+		credentials:   parameters.credentials,
+		region:        parameters.region,
+		watchInterval: parameters.watchInterval,
+		httpClient: &http.Client{
+			Transport: http.DefaultTransport.(*http.Transport).Clone(),
+		},
+		clients: make(map[clientKey]*secretsmanager.SecretsManager),
+		tracer:  tracing.Tracer(parameters.tracerProvider),
 	}
 
 	return s, nil
@@ -73,6 +124,392 @@ func (s *Service) SupportedURLSchemes(ctx context.Context) ([]string, error) {
 
 // Fetch fetches a value given its key.
 func (s *Service) Fetch(ctx context.Context, url *url.URL) ([]byte, error) {
+	ctx, span := tracing.Start(ctx, s.tracer, "confidant.Fetch", url.Scheme, "asm", trace.WithSpanKind(trace.SpanKindClient))
+	value, err := s.fetch(ctx, url)
+	tracing.End(span, err)
+	return value, err
+}
+
+// fetch fetches a value given its key.
+func (s *Service) fetch(ctx context.Context, url *url.URL) ([]byte, error) {
+	result, err := s.getSecretValue(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	return secretValue(result.SecretString, result.SecretBinary)
+}
+
+// FetchWithMetadata fetches a value and its metadata given its key.
+func (s *Service) FetchWithMetadata(ctx context.Context, url *url.URL) ([]byte, *majordomo.Metadata, error) {
+	result, err := s.getSecretValue(ctx, url)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	value, err := secretValue(result.SecretString, result.SecretBinary)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	metadata := &majordomo.Metadata{
+		ID:            aws.StringValue(result.ARN),
+		Version:       aws.StringValue(result.VersionId),
+		VersionStages: aws.StringValueSlice(result.VersionStages),
+		Created:       aws.TimeValue(result.CreatedDate),
+		Attributes: map[string]string{
+			"name": aws.StringValue(result.Name),
+		},
+	}
+
+	return value, metadata, nil
+}
+
+// getSecretValue obtains the secret value output from Amazon secrets manager.
+func (s *Service) getSecretValue(ctx context.Context, url *url.URL) (*secretsmanager.GetSecretValueOutput, error) {
+	svc, err := s.secretsManager(url)
+	if err != nil {
+		return nil, err
+	}
+	secretID, err := s.secretID(url)
+	if err != nil {
+		return nil, err
+	}
+
+	input := &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(secretID),
+	}
+	if err := setVersion(url, input); err != nil {
+		return nil, err
+	}
+
+	result, err := svc.GetSecretValueWithContext(ctx, input)
+	if err != nil {
+		return nil, asmError(err, "failed to obtain secret")
+	}
+
+	return result, nil
+}
+
+// secretValue obtains the value from the secret string or binary.
+func secretValue(secretString *string, secretBinary []byte) ([]byte, error) {
+	if secretString != nil {
+		return []byte(*secretString), nil
+	}
+	if secretBinary != nil {
+		decodedBinarySecretBytes := make([]byte, base64.StdEncoding.DecodedLen(len(secretBinary)))
+		size, err := base64.StdEncoding.Decode(decodedBinarySecretBytes, secretBinary)
+		// The encoded value is no longer required, so zero it to avoid leaving copies in memory.
+		memory.Zero(secretBinary)
+		if err != nil {
+			memory.Zero(decodedBinarySecretBytes)
+			return nil, majordomo.NewError(majordomo.ErrInvalidValue, errors.Wrap(err, "invalid secret binary"))
+		}
+		return decodedBinarySecretBytes[:size], nil
+	}
+
+	// No value but no error.
+	return nil, nil
+}
+
+// FetchBatch fetches values given their keys.
+// Secrets in the same region with the same credentials are fetched together,
+// in groups of up to 20.  Secrets with version selectors are fetched individually.
+// Batch fetches require the secretsmanager:BatchGetSecretValue permission; if
+// it is denied the secrets are fetched individually instead.
+func (s *Service) FetchBatch(ctx context.Context, urls []*url.URL) ([]*majordomo.FetchResult, error) {
+	results := make([]*majordomo.FetchResult, len(urls))
+
+	// Group the URLs that can be fetched together.
+	groups := make(map[string][]int)
+	groupOrder := make([]string, 0)
+	for i, url := range urls {
+		query := url.Query()
+		_, hasStage := query["stage"]
+		_, hasVersion := query["version"]
+		if hasStage || hasVersion {
+			value, err := s.Fetch(ctx, url)
+			results[i] = &majordomo.FetchResult{Value: value, Err: err}
+			continue
+		}
+		if url.Host == "" {
+			url.Host = s.region
+		}
+		groupKey := fmt.Sprintf("%s@%s", url.User.String(), url.Host)
+		if _, exists := groups[groupKey]; !exists {
+			groupOrder = append(groupOrder, groupKey)
+		}
+		groups[groupKey] = append(groups[groupKey], i)
+	}
+
+	for _, groupKey := range groupOrder {
+		indices := groups[groupKey]
+		for start := 0; start < len(indices); start += maxBatchSize {
+			end := start + maxBatchSize
+			if end > len(indices) {
+				end = len(indices)
+			}
+			s.fetchBatch(ctx, urls, indices[start:end], results)
+		}
+	}
+
+	return results, nil
+}
+
+// fetchBatch fetches a single batch of secrets that share region and credentials,
+// populating the results for the given indices.
+func (s *Service) fetchBatch(ctx context.Context, urls []*url.URL, indices []int, results []*majordomo.FetchResult) {
+	svc, err := s.secretsManager(urls[indices[0]])
+	if err != nil {
+		for _, i := range indices {
+			results[i] = &majordomo.FetchResult{Err: err}
+		}
+		return
+	}
+
+	secretIDs := make([]*string, 0, len(indices))
+	for _, i := range indices {
+		secretID, err := s.secretID(urls[i])
+		if err != nil {
+			results[i] = &majordomo.FetchResult{Err: err}
+			continue
+		}
+		secretIDs = append(secretIDs, aws.String(secretID))
+	}
+	if len(secretIDs) == 0 {
+		return
+	}
+
+	output, err := svc.BatchGetSecretValueWithContext(ctx, &secretsmanager.BatchGetSecretValueInput{
+		SecretIdList: secretIDs,
+	})
+	if err != nil {
+		err = asmError(err, "failed to obtain secrets")
+		if errors.Is(err, majordomo.ErrPermissionDenied) {
+			// Batch fetching requires its own permission, which may not have been
+			// granted where fetching individual secrets is allowed.
+			log.Debug().Err(err).Msg("Batch fetch denied; fetching secrets individually")
+			for _, i := range indices {
+				if results[i] == nil {
+					value, err := s.Fetch(ctx, urls[i])
+					results[i] = &majordomo.FetchResult{Value: value, Err: err}
+				}
+			}
+			return
+		}
+		for _, i := range indices {
+			if results[i] == nil {
+				results[i] = &majordomo.FetchResult{Err: err}
+			}
+		}
+		return
+	}
+
+	for _, i := range indices {
+		if results[i] != nil {
+			continue
+		}
+		secretID, _ := s.secretID(urls[i])
+		results[i] = batchResult(secretID, output)
+	}
+}
+
+// batchResult finds the result for the given secret ID in the batch output.
+func batchResult(secretID string, output *secretsmanager.BatchGetSecretValueOutput) *majordomo.FetchResult {
+	for _, entry := range output.SecretValues {
+		if secretID == aws.StringValue(entry.Name) || secretID == aws.StringValue(entry.ARN) {
+			value, err := secretValue(entry.SecretString, entry.SecretBinary)
+			return &majordomo.FetchResult{Value: value, Err: err}
+		}
+	}
+	for _, entry := range output.Errors {
+		if secretID == aws.StringValue(entry.SecretId) {
+			return &majordomo.FetchResult{
+				Err: asmError(awserr.New(aws.StringValue(entry.ErrorCode), aws.StringValue(entry.Message), nil), "failed to obtain secret"),
+			}
+		}
+	}
+
+	// Not mentioned in either values or errors.
+	return &majordomo.FetchResult{Err: majordomo.ErrNotFound}
+}
+
+// Store stores a value given its key, creating the secret if it does not exist.
+// Values that are valid UTF-8 are stored as secret strings, other values
+// are stored as secret binaries.
+func (s *Service) Store(ctx context.Context, url *url.URL, value []byte) error {
+	svc, err := s.secretsManager(url)
+	if err != nil {
+		return err
+	}
+	secretID, err := s.secretID(url)
+	if err != nil {
+		return err
+	}
+
+	var secretString *string
+	var secretBinary []byte
+	if utf8.Valid(value) {
+		secretString = aws.String(string(value))
+	} else {
+		// Fetch decodes secret binaries, so encode here to match.
+		secretBinary = make([]byte, base64.StdEncoding.EncodedLen(len(value)))
+		base64.StdEncoding.Encode(secretBinary, value)
+	}
+
+	// Zero the encoded value once it has been sent, to avoid leaving copies in memory.
+	defer memory.Zero(secretBinary)
+
+	_, err = svc.PutSecretValueWithContext(ctx, &secretsmanager.PutSecretValueInput{
+		SecretId:     aws.String(secretID),
+		SecretString: secretString,
+		SecretBinary: secretBinary,
+	})
+	if err == nil {
+		return nil
+	}
+	if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != secretsmanager.ErrCodeResourceNotFoundException {
+		return asmError(err, "failed to store secret")
+	}
+
+	// Secret does not exist; create it.
+	log.Trace().Str("secret_id", secretID).Msg("Secret not found; creating")
+	_, err = svc.CreateSecretWithContext(ctx, &secretsmanager.CreateSecretInput{
+		Name:         aws.String(secretID),
+		SecretString: secretString,
+		SecretBinary: secretBinary,
+	})
+	if err != nil {
+		return asmError(err, "failed to create secret")
+	}
+
+	return nil
+}
+
+// Delete deletes a value given its key.
+// The secret is scheduled for deletion with the default recovery window.
+func (s *Service) Delete(ctx context.Context, url *url.URL) error {
+	svc, err := s.secretsManager(url)
+	if err != nil {
+		return err
+	}
+	secretID, err := s.secretID(url)
+	if err != nil {
+		return err
+	}
+
+	_, err = svc.DeleteSecretWithContext(ctx, &secretsmanager.DeleteSecretInput{
+		SecretId: aws.String(secretID),
+	})
+	if err != nil {
+		return asmError(err, "failed to delete secret")
+	}
+
+	return nil
+}
+
+// List lists the secrets whose names start with the prefix given in the URL.
+// A URL of the form "asm://region/prefix" will list the secrets in the given
+// region whose names start with "prefix".  Returned keys do not contain
+// credentials.
+func (s *Service) List(ctx context.Context, prefix *url.URL, pageToken string, pageSize int) ([]string, string, error) {
+	svc, err := s.secretsManager(prefix)
+	if err != nil {
+		return nil, "", err
+	}
+
+	input := &secretsmanager.ListSecretsInput{}
+	namePrefix := strings.TrimPrefix(prefix.Path, "/")
+	if namePrefix != "" {
+		input.Filters = []*secretsmanager.Filter{
+			{
+				Key:    aws.String(secretsmanager.FilterNameStringTypeName),
+				Values: []*string{aws.String(namePrefix)},
+			},
+		}
+	}
+	if pageToken != "" {
+		input.NextToken = aws.String(pageToken)
+	}
+	if pageSize > 0 {
+		input.MaxResults = aws.Int64(int64(pageSize))
+	}
+
+	result, err := svc.ListSecretsWithContext(ctx, input)
+	if err != nil {
+		return nil, "", asmError(err, "failed to list secrets")
+	}
+
+	keys := make([]string, 0, len(result.SecretList))
+	for _, entry := range result.SecretList {
+		name := aws.StringValue(entry.Name)
+		// The name filter is a prefix match, but check here in case it changes.
+		if !strings.HasPrefix(name, namePrefix) {
+			continue
+		}
+		keys = append(keys, fmt.Sprintf("asm://%s/%s", prefix.Host, name))
+	}
+
+	return keys, aws.StringValue(result.NextToken), nil
+}
+
+// setVersion sets the version selectors in the input from the URL.
+func setVersion(url *url.URL, input *secretsmanager.GetSecretValueInput) error {
+	query := url.Query()
+	if stages, exists := query["stage"]; exists {
+		if len(stages) != 1 || !stageRegex.MatchString(stages[0]) {
+			return majordomo.ErrURLInvalid
+		}
+		input.VersionStage = aws.String(stages[0])
+	}
+	if versions, exists := query["version"]; exists {
+		if len(versions) != 1 || !versionRegex.MatchString(versions[0]) {
+			return majordomo.ErrURLInvalid
+		}
+		input.VersionId = aws.String(versions[0])
+	}
+
+	return nil
+}
+
+// Ping checks that the default credentials are valid.
+// If a default region is configured the credentials are checked with the
+// security token service, otherwise they are only checked for presence.
+func (s *Service) Ping(ctx context.Context) error {
+	creds := s.credentials
+	if creds == nil {
+		creds = credentials.NewEnvCredentials()
+	}
+	if _, err := creds.GetWithContext(ctx); err != nil {
+		return majordomo.NewError(majordomo.ErrPermissionDenied, errors.Wrap(err, "failed to obtain credentials"))
+	}
+	if s.region == "" {
+		return nil
+	}
+
+	session, err := session.NewSession(aws.NewConfig().WithRegion(s.region).WithCredentials(creds).WithHTTPClient(s.httpClient))
+	if err != nil {
+		return errors.Wrap(err, "failed to initiate session with Amazon security token service")
+	}
+	if _, err := sts.New(session).GetCallerIdentityWithContext(ctx, &sts.GetCallerIdentityInput{}); err != nil {
+		return asmError(err, "failed to validate credentials")
+	}
+
+	return nil
+}
+
+// secretID obtains the secret ID from the URL.
+func (s *Service) secretID(url *url.URL) (string, error) {
+	secretID := strings.TrimPrefix(url.Path, "/")
+	if secretID == "" {
+		return "", errors.New("no secret specified")
+	}
+	return secretID, nil
+}
+
+// secretsManager returns a secrets manager client for the given URL,
+// creating it if required.
+func (s *Service) secretsManager(url *url.URL) (*secretsmanager.SecretsManager, error) {
 	if url.Host == "" {
 		url.Host = s.region
 	}
@@ -80,51 +517,96 @@ func (s *Service) Fetch(ctx context.Context, url *url.URL) ([]byte, error) {
 		return nil, errors.New("no region specified")
 	}
 
-	if url.Path == "" {
-		return nil, errors.New("no secret specified")
+	key := clientKey{
+		region: url.Host,
+	}
+	password, hasPassword := url.User.Password()
+	if hasPassword {
+		key.id = url.User.Username()
+		key.secret = password
+	}
+
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
+
+	if s.closed {
+		return nil, errClosed
+	}
+	if client, exists := s.clients[key]; exists {
+		return client, nil
 	}
 
 	var creds *credentials.Credentials
-	password, hasPassword := url.User.Password()
 	switch {
 	case hasPassword:
-		creds = credentials.NewStaticCredentials(url.User.Username(), password, "")
+		creds = credentials.NewStaticCredentials(key.id, key.secret, "")
 	case s.credentials != nil:
 		creds = s.credentials
 	default:
 		creds = credentials.NewEnvCredentials()
 	}
-	session, err := session.NewSession(aws.NewConfig().WithRegion(url.Host).WithCredentials(creds))
+	session, err := session.NewSession(aws.NewConfig().WithRegion(url.Host).WithCredentials(creds).WithHTTPClient(s.httpClient))
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to initiate session with Amazon secrets manager")
 	}
-	svc := secretsmanager.New(session)
-	input := &secretsmanager.GetSecretValueInput{
-		SecretId: aws.String(strings.TrimPrefix(url.Path, "/")),
-	}
+	client := secretsmanager.New(session)
+	s.clients[key] = client
 
-	result, err := svc.GetSecretValue(input)
-	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			if aerr.Code() == secretsmanager.ErrCodeResourceNotFoundException {
-				return nil, majordomo.ErrNotFound
-			}
-		}
-		return nil, errors.Wrap(err, "failed to obtain secret")
-	}
+	return client, nil
+}
 
-	if result.SecretString != nil {
-		return []byte(*result.SecretString), nil
-	}
-	if result.SecretBinary != nil {
-		decodedBinarySecretBytes := make([]byte, base64.StdEncoding.DecodedLen(len(result.SecretBinary)))
-		size, err := base64.StdEncoding.Decode(decodedBinarySecretBytes, result.SecretBinary)
-		if err != nil {
-			return nil, errors.Wrap(err, "invalid secret binary")
-		}
-		return decodedBinarySecretBytes[:size], nil
+// Close releases the clients and their connections.
+// Requests that are in progress when the service is closed may fail, and
+// subsequent requests will fail.
+func (s *Service) Close(ctx context.Context) error {
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
+
+	s.closed = true
+	s.clients = make(map[clientKey]*secretsmanager.SecretsManager)
+	s.httpClient.CloseIdleConnections()
+
+	return nil
+}
+
+// Watch watches a value given its key, polling for changes at the configured interval.
+func (s *Service) Watch(ctx context.Context, url *url.URL) (<-chan *majordomo.WatchEvent, error) {
+	return poll.Watch(ctx, s.watchInterval, func(ctx context.Context) ([]byte, error) {
+		return s.Fetch(ctx, url)
+	}), nil
+}
+
+// asmError maps Amazon secrets manager errors to majordomo well-known errors where possible.
+func asmError(err error, msg string) error {
+	aerr, ok := err.(awserr.Error)
+	if !ok {
+		return errors.Wrap(err, msg)
 	}
 
-	// No value but no error.
-	return nil, nil
+	switch aerr.Code() {
+	case secretsmanager.ErrCodeResourceNotFoundException:
+		return majordomo.ErrNotFound
+	case "AccessDeniedException",
+		"UnrecognizedClientException",
+		"InvalidClientTokenId",
+		"ExpiredTokenException",
+		"ExpiredToken",
+		"SignatureDoesNotMatch",
+		"IncompleteSignature",
+		"InvalidSignatureException",
+		secretsmanager.ErrCodeDecryptionFailure:
+		return majordomo.NewError(majordomo.ErrPermissionDenied, errors.Wrap(err, msg))
+	case secretsmanager.ErrCodeInternalServiceError,
+		"ThrottlingException",
+		"ServiceUnavailable",
+		request.ErrCodeRequestError:
+		return majordomo.NewError(majordomo.ErrUnavailable, errors.Wrap(err, msg))
+	case request.CanceledErrorCode,
+		request.ErrCodeResponseTimeout:
+		return majordomo.NewError(majordomo.ErrTimeout, errors.Wrap(err, msg))
+	case secretsmanager.ErrCodeInvalidParameterException:
+		return majordomo.NewError(majordomo.ErrURLInvalid, errors.Wrap(err, msg))
+	default:
+		return errors.Wrap(err, msg)
+	}
 }
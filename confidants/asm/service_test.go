@@ -14,12 +14,25 @@
 package asm_test
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"sync"
 	"testing"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
 	"github.com/wealdtech/go-majordomo"
@@ -147,3 +160,331 @@ func TestOverrides(t *testing.T) {
 	assert.Equal(t, string(secret2), string(secret3))
 	assert.Equal(t, string(secret3), string(secret4))
 }
+
+func TestInvalidVersion(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{
+			name: "StageEmpty",
+			key:  "asm://region/secret?stage=",
+		},
+		{
+			name: "StageInvalidCharacters",
+			key:  "asm://region/secret?stage=a/b",
+		},
+		{
+			name: "StageMultiple",
+			key:  "asm://region/secret?stage=AWSCURRENT&stage=AWSPREVIOUS",
+		},
+		{
+			name: "VersionEmpty",
+			key:  "asm://region/secret?version=",
+		},
+		{
+			name: "VersionShort",
+			key:  "asm://region/secret?version=abc",
+		},
+	}
+
+	ctx := context.Background()
+	service, err := standard.New(ctx)
+	require.NoError(t, err)
+	confidant, err := asm.New(ctx, asm.WithLogLevel(zerolog.Disabled))
+	require.NoError(t, err)
+	require.NoError(t, service.RegisterConfidant(ctx, confidant))
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := service.Fetch(ctx, test.key)
+			require.EqualError(t, err, majordomo.ErrURLInvalid.Error())
+		})
+	}
+}
+
+func TestClose(t *testing.T) {
+	ctx := context.Background()
+	confidant, err := asm.New(ctx, asm.WithLogLevel(zerolog.Disabled))
+	require.NoError(t, err)
+	require.NoError(t, confidant.Close(ctx))
+
+	// Closing again is allowed.
+	require.NoError(t, confidant.Close(ctx))
+
+	service, err := standard.New(ctx)
+	require.NoError(t, err)
+	require.NoError(t, service.RegisterConfidant(ctx, confidant))
+	_, err = service.Fetch(ctx, "asm://region/secret")
+	require.ErrorIs(t, err, majordomo.ErrUnavailable)
+}
+
+func TestBatchResult(t *testing.T) {
+	output := &secretsmanager.BatchGetSecretValueOutput{
+		SecretValues: []*secretsmanager.SecretValueEntry{
+			{
+				Name:         aws.String("string"),
+				ARN:          aws.String("arn:aws:secretsmanager:eu-west-1:123456789012:secret:string-AbCdEf"),
+				SecretString: aws.String("string value"),
+			},
+			{
+				Name:         aws.String("binary"),
+				SecretBinary: []byte("YmluYXJ5IHZhbHVl"),
+			},
+			{
+				Name: aws.String("empty"),
+			},
+		},
+		Errors: []*secretsmanager.APIErrorType{
+			{
+				SecretId:  aws.String("missing"),
+				ErrorCode: aws.String(secretsmanager.ErrCodeResourceNotFoundException),
+				Message:   aws.String("Secrets Manager can't find the specified secret."),
+			},
+			{
+				SecretId:  aws.String("denied"),
+				ErrorCode: aws.String("AccessDeniedException"),
+				Message:   aws.String("Access denied."),
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		secretID string
+		value    []byte
+		err      error
+	}{
+		{
+			name:     "Name",
+			secretID: "string",
+			value:    []byte("string value"),
+		},
+		{
+			name:     "ARN",
+			secretID: "arn:aws:secretsmanager:eu-west-1:123456789012:secret:string-AbCdEf",
+			value:    []byte("string value"),
+		},
+		{
+			name:     "Binary",
+			secretID: "binary",
+			value:    []byte("binary value"),
+		},
+		{
+			name:     "Empty",
+			secretID: "empty",
+		},
+		{
+			name:     "NotFound",
+			secretID: "missing",
+			err:      majordomo.ErrNotFound,
+		},
+		{
+			name:     "Denied",
+			secretID: "denied",
+			err:      majordomo.ErrPermissionDenied,
+		},
+		{
+			name:     "Absent",
+			secretID: "absent",
+			err:      majordomo.ErrNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := asm.BatchResult(test.secretID, output)
+			require.Equal(t, test.value, result.Value)
+			if test.err != nil {
+				require.True(t, errors.Is(result.Err, test.err))
+			} else {
+				require.NoError(t, result.Err)
+			}
+		})
+	}
+}
+
+func TestASMError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		kind error
+		msg  string
+	}{
+		{
+			name: "NotAWS",
+			err:  errors.New("bad"),
+			msg:  "failed: bad",
+		},
+		{
+			name: "NotFound",
+			err:  awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "not found", nil),
+			kind: majordomo.ErrNotFound,
+		},
+		{
+			name: "AccessDenied",
+			err:  awserr.New("AccessDeniedException", "denied", nil),
+			kind: majordomo.ErrPermissionDenied,
+			msg:  "permission denied: failed: AccessDeniedException: denied",
+		},
+		{
+			name: "ExpiredToken",
+			err:  awserr.New("ExpiredTokenException", "expired", nil),
+			kind: majordomo.ErrPermissionDenied,
+		},
+		{
+			name: "DecryptionFailure",
+			err:  awserr.New(secretsmanager.ErrCodeDecryptionFailure, "kms", nil),
+			kind: majordomo.ErrPermissionDenied,
+		},
+		{
+			name: "InternalServiceError",
+			err:  awserr.New(secretsmanager.ErrCodeInternalServiceError, "internal", nil),
+			kind: majordomo.ErrUnavailable,
+		},
+		{
+			name: "Throttling",
+			err:  awserr.New("ThrottlingException", "slow down", nil),
+			kind: majordomo.ErrUnavailable,
+		},
+		{
+			name: "RequestError",
+			err:  awserr.New(request.ErrCodeRequestError, "send request failed", nil),
+			kind: majordomo.ErrUnavailable,
+		},
+		{
+			name: "Canceled",
+			err:  awserr.New(request.CanceledErrorCode, "canceled", nil),
+			kind: majordomo.ErrTimeout,
+		},
+		{
+			name: "ResponseTimeout",
+			err:  awserr.New(request.ErrCodeResponseTimeout, "timeout", nil),
+			kind: majordomo.ErrTimeout,
+		},
+		{
+			name: "InvalidParameter",
+			err:  awserr.New(secretsmanager.ErrCodeInvalidParameterException, "invalid", nil),
+			kind: majordomo.ErrURLInvalid,
+		},
+		{
+			name: "Other",
+			err:  awserr.New("SomethingElse", "other", nil),
+			msg:  "failed: SomethingElse: other",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := asm.ASMError(test.err, "failed")
+			if test.kind != nil {
+				require.True(t, errors.Is(err, test.kind))
+			} else {
+				for _, kind := range []error{majordomo.ErrNotFound, majordomo.ErrPermissionDenied, majordomo.ErrUnavailable, majordomo.ErrTimeout, majordomo.ErrURLInvalid} {
+					require.False(t, errors.Is(err, kind))
+				}
+			}
+			if test.msg != "" {
+				require.EqualError(t, err, test.msg)
+			}
+		})
+	}
+}
+
+func TestFetchBatchDenied(t *testing.T) {
+	ctx := context.Background()
+	confidant, err := asm.New(ctx,
+		asm.WithLogLevel(zerolog.Disabled),
+		asm.WithRegion("eu-west-1"),
+		asm.WithCredentials(credentials.NewStaticCredentials("id", "secret", "")),
+	)
+	require.NoError(t, err)
+	secretsManager := &MockSecretsManager{
+		denied: map[string]bool{"secretsmanager.BatchGetSecretValue": true},
+	}
+	server := httptest.NewTLSServer(secretsManager)
+	defer server.Close()
+	// Send all requests to the mock server.
+	confidant.SetHTTPClient(&http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network string, addr string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+			},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	})
+
+	url1, err := url.Parse("asm:///one")
+	require.NoError(t, err)
+	url2, err := url.Parse("asm:///two")
+	require.NoError(t, err)
+
+	// Secrets are fetched individually if the batch fetch is denied.
+	results, err := confidant.FetchBatch(ctx, []*url.URL{url1, url2})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	require.NoError(t, results[0].Err)
+	require.Equal(t, []byte("one value"), results[0].Value)
+	require.NoError(t, results[1].Err)
+	require.Equal(t, []byte("two value"), results[1].Value)
+	require.Equal(t, []string{
+		"secretsmanager.BatchGetSecretValue",
+		"secretsmanager.GetSecretValue",
+		"secretsmanager.GetSecretValue",
+	}, secretsManager.Targets())
+
+	// Individual fetches that are denied return permission denied.
+	secretsManager.Deny("secretsmanager.GetSecretValue")
+	results, err = confidant.FetchBatch(ctx, []*url.URL{url1})
+	require.NoError(t, err)
+	require.True(t, errors.Is(results[0].Err, majordomo.ErrPermissionDenied))
+}
+
+// MockSecretsManager is a mock Amazon secrets manager server that denies
+// access to the given operations.
+type MockSecretsManager struct {
+	mu      sync.Mutex
+	denied  map[string]bool
+	targets []string
+}
+
+func (m *MockSecretsManager) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	target := req.Header.Get("X-Amz-Target")
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	m.mu.Lock()
+	m.targets = append(m.targets, target)
+	denied := m.denied[target]
+	m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	switch {
+	case denied:
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"__type":"AccessDeniedException","message":"not authorized"}`))
+	case target == "secretsmanager.GetSecretValue":
+		name := "two"
+		if bytes.Contains(body, []byte(`"one"`)) {
+			name = "one"
+		}
+		_, _ = w.Write([]byte(fmt.Sprintf(`{"Name":%q,"SecretString":"%s value"}`, name, name)))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"__type":"InvalidRequestException","message":"unexpected"}`))
+	}
+}
+
+func (m *MockSecretsManager) Deny(target string) {
+	m.mu.Lock()
+	m.denied[target] = true
+	m.mu.Unlock()
+}
+
+func (m *MockSecretsManager) Targets() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.targets
+}
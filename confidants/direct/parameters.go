@@ -15,10 +15,12 @@ package direct
 
 import (
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type parameters struct {
-	logLevel zerolog.Level
+	logLevel       zerolog.Level
+	tracerProvider trace.TracerProvider
 }
 
 // Parameter is the interface for service parameters.
@@ -39,6 +41,14 @@ func WithLogLevel(logLevel zerolog.Level) Parameter {
 	})
 }
 
+// WithTracerProvider sets the OpenTelemetry tracer provider used to create
+// spans.  If not supplied no spans are created.
+func WithTracerProvider(provider trace.TracerProvider) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.tracerProvider = provider
+	})
+}
+
 // parseAndCheckParameters parses and checks parameters to ensure that mandatory parameters are present and correct.
 func parseAndCheckParameters(params ...Parameter) (*parameters, error) {
 	parameters := parameters{
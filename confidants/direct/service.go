@@ -21,13 +21,17 @@ import (
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 	zerologger "github.com/rs/zerolog/log"
+	"github.com/wealdtech/go-majordomo/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Service returns returns values reflected from the key.
 // This service handles URLs with the scheme "direct".
 // It returns the path as the value, minus the leading "/".
 // For example a URL "direct:///secret" will return "secret".
-type Service struct{}
+type Service struct {
+	tracer trace.Tracer
+}
 
 // module-wide log.
 var log zerolog.Logger
@@ -45,7 +49,9 @@ func New(ctx context.Context, params ...Parameter) (*Service, error) {
 		log = log.Level(parameters.logLevel)
 	}
 
-	s := &Service{}
+	s := &Service{
+		tracer: tracing.Tracer(parameters.tracerProvider),
+	}
 
 	return s, nil
 }
@@ -57,6 +63,14 @@ func (s *Service) SupportedURLSchemes(ctx context.Context) ([]string, error) {
 
 // Fetch fetches a value given its key URL.
 func (s *Service) Fetch(ctx context.Context, url *url.URL) ([]byte, error) {
+	ctx, span := tracing.Start(ctx, s.tracer, "confidant.Fetch", url.Scheme, "direct")
+	value, err := s.fetch(ctx, url)
+	tracing.End(span, err)
+	return value, err
+}
+
+// fetch fetches a value given its key URL.
+func (s *Service) fetch(ctx context.Context, url *url.URL) ([]byte, error) {
 	value := strings.TrimPrefix(url.Path, "/")
 	if value == "" {
 		return nil, nil
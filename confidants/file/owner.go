@@ -0,0 +1,33 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package file
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// addOwnerAttributes adds the owner of the file to the metadata attributes.
+func addOwnerAttributes(info os.FileInfo, attributes map[string]string) {
+	stat, isStat := info.Sys().(*syscall.Stat_t)
+	if !isStat {
+		return
+	}
+	attributes["uid"] = fmt.Sprintf("%d", stat.Uid)
+	attributes["gid"] = fmt.Sprintf("%d", stat.Gid)
+}
@@ -15,10 +15,13 @@ package file
 
 import (
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type parameters struct {
-	logLevel zerolog.Level
+	logLevel       zerolog.Level
+	probePath      string
+	tracerProvider trace.TracerProvider
 }
 
 // Parameter is the interface for service parameters.
@@ -39,6 +42,21 @@ func WithLogLevel(logLevel zerolog.Level) Parameter {
 	})
 }
 
+// WithProbePath sets the directory checked for accessibility to check the health of the confidant.
+func WithProbePath(probePath string) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.probePath = probePath
+	})
+}
+
+// WithTracerProvider sets the OpenTelemetry tracer provider used to create
+// spans.  If not supplied no spans are created.
+func WithTracerProvider(provider trace.TracerProvider) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.tracerProvider = provider
+	})
+}
+
 // parseAndCheckParameters parses and checks parameters to ensure that mandatory parameters are present and correct.
 func parseAndCheckParameters(params ...Parameter) (*parameters, error) {
 	parameters := parameters{
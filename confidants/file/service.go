@@ -15,14 +15,22 @@ package file
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 	zerologger "github.com/rs/zerolog/log"
 	"github.com/wealdtech/go-majordomo"
+	"github.com/wealdtech/go-majordomo/internal/poll"
+	"github.com/wealdtech/go-majordomo/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Service returns the values from the filesystem.
@@ -30,7 +38,10 @@ import (
 // It returns the file at the path as the value.
 // For example a URL "direct:///home/me/secret.txt" will return the contents
 // of the file "/home/me/secret.txt"
-type Service struct{}
+type Service struct {
+	probePath string
+	tracer    trace.Tracer
+}
 
 // module-wide log.
 var log zerolog.Logger
@@ -48,7 +59,10 @@ func New(ctx context.Context, params ...Parameter) (*Service, error) {
 		log = log.Level(parameters.logLevel)
 	}
 
-	s := &Service{}
+	s := &Service{
+		probePath: parameters.probePath,
+		tracer:    tracing.Tracer(parameters.tracerProvider),
+	}
 
 	return s, nil
 }
@@ -60,12 +74,268 @@ func (s *Service) SupportedURLSchemes(ctx context.Context) ([]string, error) {
 
 // Fetch fetches a value given its file URL.
 func (s *Service) Fetch(ctx context.Context, url *url.URL) ([]byte, error) {
+	ctx, span := tracing.Start(ctx, s.tracer, "confidant.Fetch", url.Scheme, "file")
+	value, err := s.fetch(ctx, url)
+	tracing.End(span, err)
+	return value, err
+}
+
+// fetch fetches a value given its file URL.
+func (s *Service) fetch(ctx context.Context, url *url.URL) ([]byte, error) {
 	data, err := ioutil.ReadFile(url.Path)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, majordomo.ErrNotFound
-		}
-		return nil, errors.Wrap(err, "failed to fetch value")
+		return nil, fileError(err, "failed to fetch value")
 	}
 	return data, nil
 }
+
+// FetchStream fetches a value given its file URL, returning a reader for the value.
+func (s *Service) FetchStream(ctx context.Context, url *url.URL, maxSize int64) (io.ReadCloser, error) {
+	file, err := os.Open(url.Path)
+	if err != nil {
+		return nil, fileError(err, "failed to fetch value")
+	}
+
+	// Reject oversized files up front where possible.
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, errors.Wrap(err, "failed to obtain file information")
+	}
+	if maxSize > 0 && info.Mode().IsRegular() && info.Size() > maxSize {
+		file.Close()
+		return nil, majordomo.ErrValueTooLarge
+	}
+
+	return majordomo.NewLimitedReadCloser(file, maxSize), nil
+}
+
+// FetchWithMetadata fetches a value and its metadata given its file URL.
+// The metadata contains the modification time of the file, and its mode and
+// ownership as attributes.
+func (s *Service) FetchWithMetadata(ctx context.Context, url *url.URL) ([]byte, *majordomo.Metadata, error) {
+	data, err := s.fetch(ctx, url)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info, err := os.Stat(url.Path)
+	if err != nil {
+		return nil, nil, fileError(err, "failed to obtain file information")
+	}
+
+	metadata := &majordomo.Metadata{
+		ID:       url.Path,
+		Modified: info.ModTime(),
+		Attributes: map[string]string{
+			"mode": info.Mode().Perm().String(),
+		},
+	}
+	addOwnerAttributes(info, metadata.Attributes)
+
+	return data, metadata, nil
+}
+
+// Store stores a value given its file URL.
+// The value is written to a temporary file in the same directory and then
+// renamed, so readers will see either the old or the new value but never a
+// partial write.  The file is only readable and writable by its owner.
+func (s *Service) Store(ctx context.Context, url *url.URL, value []byte) error {
+	if url.Path == "" {
+		return majordomo.ErrURLInvalid
+	}
+
+	dir := filepath.Dir(url.Path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fileError(err, "failed to create directory")
+	}
+
+	tmpFile, err := ioutil.TempFile(dir, fmt.Sprintf(".%s-*", filepath.Base(url.Path)))
+	if err != nil {
+		return fileError(err, "failed to create temporary file")
+	}
+	// Remove the temporary file if we fail before renaming it.
+	defer func() {
+		if err := os.Remove(tmpFile.Name()); err != nil && !os.IsNotExist(err) {
+			log.Debug().Err(err).Msg("Failed to remove temporary file")
+		}
+	}()
+
+	if err := tmpFile.Chmod(0600); err != nil {
+		tmpFile.Close()
+		return errors.Wrap(err, "failed to set permissions on temporary file")
+	}
+	if _, err := tmpFile.Write(value); err != nil {
+		tmpFile.Close()
+		return errors.Wrap(err, "failed to write value")
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return errors.Wrap(err, "failed to sync value")
+	}
+	if err := tmpFile.Close(); err != nil {
+		return errors.Wrap(err, "failed to close temporary file")
+	}
+
+	if err := os.Rename(tmpFile.Name(), url.Path); err != nil {
+		return fileError(err, "failed to store value")
+	}
+
+	return nil
+}
+
+// Delete deletes a value given its file URL.
+func (s *Service) Delete(ctx context.Context, url *url.URL) error {
+	if url.Path == "" {
+		return majordomo.ErrURLInvalid
+	}
+
+	if err := os.Remove(url.Path); err != nil {
+		return fileError(err, "failed to delete value")
+	}
+
+	return nil
+}
+
+// List lists the files in the directory given by the URL.
+// A URL ending in "/", for example "file:///etc/secrets/", lists all files
+// in that directory.  Otherwise the final element of the path is used as a
+// filename prefix, for example "file:///etc/secrets/db-" lists all files in
+// "/etc/secrets" whose names start with "db-".  Subdirectories are not
+// traversed, and hidden files are only listed if the prefix is itself hidden.
+// The page token is the name of the last file returned in the previous page.
+func (s *Service) List(ctx context.Context, prefix *url.URL, pageToken string, pageSize int) ([]string, string, error) {
+	dir := prefix.Path
+	namePrefix := ""
+	if !strings.HasSuffix(dir, "/") {
+		dir, namePrefix = filepath.Split(dir)
+	}
+	if dir == "" {
+		return nil, "", majordomo.ErrURLInvalid
+	}
+
+	// ReadDir returns entries sorted by name, which provides stable paging.
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, "", fileError(err, "failed to list directory")
+	}
+
+	keys := make([]string, 0)
+	nextPageToken := ""
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Mode().IsRegular() ||
+			!strings.HasPrefix(name, namePrefix) ||
+			(strings.HasPrefix(name, ".") && !strings.HasPrefix(namePrefix, ".")) ||
+			name <= pageToken {
+			continue
+		}
+		if pageSize > 0 && len(keys) == pageSize {
+			nextPageToken = filepath.Base(keys[len(keys)-1])
+			break
+		}
+		keys = append(keys, fmt.Sprintf("file://%s", filepath.Join(dir, name)))
+	}
+
+	return keys, nextPageToken, nil
+}
+
+// Watch watches a value given its file URL.
+// The directory containing the file is watched, rather than the file itself,
+// so that changes made by replacing the file (as done by Store, and by
+// orchestrators that update mounted secrets) are observed.
+func (s *Service) Watch(ctx context.Context, url *url.URL) (<-chan *majordomo.WatchEvent, error) {
+	if url.Path == "" {
+		return nil, majordomo.ErrURLInvalid
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create watcher")
+	}
+	if err := watcher.Add(filepath.Dir(url.Path)); err != nil {
+		watcher.Close()
+		return nil, fileError(err, "failed to watch directory")
+	}
+
+	ch := make(chan *majordomo.WatchEvent, 1)
+	go func() {
+		defer close(ch)
+		defer watcher.Close()
+
+		var last *majordomo.WatchEvent
+		send := func() bool {
+			value, err := s.Fetch(ctx, url)
+			event := &majordomo.WatchEvent{
+				Value: value,
+				Err:   err,
+			}
+			if !poll.Changed(last, event) {
+				return true
+			}
+			select {
+			case ch <- event:
+				last = event
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		if !send() {
+			return
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				// Any change in the directory could affect the file, for
+				// example if it is a symbolic link, so re-read it.
+				if !send() {
+					return
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Debug().Err(err).Msg("Watcher returned an error")
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// Ping checks that the probe directory, if configured, is accessible.
+func (s *Service) Ping(ctx context.Context) error {
+	if s.probePath == "" {
+		return nil
+	}
+
+	dir, err := os.Open(s.probePath)
+	if err != nil {
+		return fileError(err, "failed to open probe directory")
+	}
+	defer dir.Close()
+	if _, err := dir.Readdirnames(1); err != nil && err != io.EOF {
+		return fileError(err, "failed to read probe directory")
+	}
+
+	return nil
+}
+
+// fileError maps filesystem errors to majordomo well-known errors where possible.
+func fileError(err error, msg string) error {
+	switch {
+	case os.IsNotExist(err):
+		return majordomo.ErrNotFound
+	case os.IsPermission(err):
+		return majordomo.NewError(majordomo.ErrPermissionDenied, errors.Wrap(err, msg))
+	default:
+		return errors.Wrap(err, msg)
+	}
+}
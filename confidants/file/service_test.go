@@ -17,8 +17,10 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
@@ -76,3 +78,223 @@ func TestFetch(t *testing.T) {
 		})
 	}
 }
+
+func TestStoreDelete(t *testing.T) {
+	base, err := ioutil.TempDir("", "TestStoreDelete")
+	require.NoError(t, err)
+	defer os.RemoveAll(base)
+	secretPath := filepath.Join(base, "subdir", "secret-key")
+	key := fmt.Sprintf("file://%s", secretPath)
+
+	ctx := context.Background()
+	service, err := standard.New(ctx)
+	require.NoError(t, err)
+	confidant, err := file.New(ctx, file.WithLogLevel(zerolog.Disabled))
+	require.NoError(t, err)
+	require.NoError(t, service.RegisterConfidant(ctx, confidant))
+
+	// Store a new value.
+	require.NoError(t, service.Store(ctx, key, []byte("secret value")))
+	value, err := service.Fetch(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("secret value"), value)
+	info, err := os.Stat(secretPath)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// Overwrite the value.
+	require.NoError(t, service.Store(ctx, key, []byte("new secret value")))
+	value, err = service.Fetch(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("new secret value"), value)
+
+	// No temporary files left behind.
+	files, err := ioutil.ReadDir(filepath.Dir(secretPath))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	// Delete the value.
+	require.NoError(t, service.Delete(ctx, key))
+	_, err = service.Fetch(ctx, key)
+	require.EqualError(t, err, majordomo.ErrNotFound.Error())
+	require.EqualError(t, service.Delete(ctx, key), majordomo.ErrNotFound.Error())
+}
+
+func TestList(t *testing.T) {
+	base, err := ioutil.TempDir("", "TestList")
+	require.NoError(t, err)
+	defer os.RemoveAll(base)
+	for _, name := range []string{"db-password", "db-user", "api-key", ".hidden"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(base, name), []byte(name), 0600))
+	}
+	require.NoError(t, os.Mkdir(filepath.Join(base, "subdir"), 0700))
+
+	ctx := context.Background()
+	service, err := standard.New(ctx)
+	require.NoError(t, err)
+	confidant, err := file.New(ctx, file.WithLogLevel(zerolog.Disabled))
+	require.NoError(t, err)
+	require.NoError(t, service.RegisterConfidant(ctx, confidant))
+
+	// All files in the directory.
+	keys, err := service.List(ctx, fmt.Sprintf("file://%s/", base))
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		fmt.Sprintf("file://%s/api-key", base),
+		fmt.Sprintf("file://%s/db-password", base),
+		fmt.Sprintf("file://%s/db-user", base),
+	}, keys)
+
+	// Files with a prefix.
+	keys, err = service.List(ctx, fmt.Sprintf("file://%s/db-", base))
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		fmt.Sprintf("file://%s/db-password", base),
+		fmt.Sprintf("file://%s/db-user", base),
+	}, keys)
+
+	// Paging.
+	keys, pageToken, err := service.ListPage(ctx, fmt.Sprintf("file://%s/", base), "", 2)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	require.Equal(t, "db-password", pageToken)
+	keys, pageToken, err = service.ListPage(ctx, fmt.Sprintf("file://%s/", base), pageToken, 2)
+	require.NoError(t, err)
+	require.Equal(t, []string{fmt.Sprintf("file://%s/db-user", base)}, keys)
+	require.Equal(t, "", pageToken)
+
+	// Missing directory.
+	_, err = service.List(ctx, fmt.Sprintf("file://%s/missing/", base))
+	require.EqualError(t, err, majordomo.ErrNotFound.Error())
+}
+
+func TestFetchWithMetadata(t *testing.T) {
+	base, err := ioutil.TempDir("", "TestFetchWithMetadata")
+	require.NoError(t, err)
+	defer os.RemoveAll(base)
+	secretPath := filepath.Join(base, "secret-key")
+	require.NoError(t, ioutil.WriteFile(secretPath, []byte("secret value"), 0600))
+	info, err := os.Stat(secretPath)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	service, err := standard.New(ctx)
+	require.NoError(t, err)
+	confidant, err := file.New(ctx, file.WithLogLevel(zerolog.Disabled))
+	require.NoError(t, err)
+	require.NoError(t, service.RegisterConfidant(ctx, confidant))
+
+	value, metadata, err := service.FetchWithMetadata(ctx, fmt.Sprintf("file://%s", secretPath))
+	require.NoError(t, err)
+	require.Equal(t, []byte("secret value"), value)
+	require.Equal(t, secretPath, metadata.ID)
+	require.Equal(t, info.ModTime(), metadata.Modified)
+	require.Equal(t, "-rw-------", metadata.Attributes["mode"])
+
+	_, _, err = service.FetchWithMetadata(ctx, fmt.Sprintf("file://%s2", secretPath))
+	require.EqualError(t, err, majordomo.ErrNotFound.Error())
+}
+
+func TestWatch(t *testing.T) {
+	base, err := ioutil.TempDir("", "TestWatch")
+	require.NoError(t, err)
+	defer os.RemoveAll(base)
+	secretPath := filepath.Join(base, "secret-key")
+	require.NoError(t, ioutil.WriteFile(secretPath, []byte("secret value"), 0600))
+	key := fmt.Sprintf("file://%s", secretPath)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	service, err := standard.New(ctx)
+	require.NoError(t, err)
+	confidant, err := file.New(ctx, file.WithLogLevel(zerolog.Disabled))
+	require.NoError(t, err)
+	require.NoError(t, service.RegisterConfidant(ctx, confidant))
+
+	ch, err := service.Watch(ctx, key)
+	require.NoError(t, err)
+
+	// Initial value.
+	event := <-ch
+	require.NoError(t, event.Err)
+	require.Equal(t, []byte("secret value"), event.Value)
+
+	// Updated value.
+	require.NoError(t, service.Store(ctx, key, []byte("new secret value")))
+	select {
+	case event = <-ch:
+		require.NoError(t, event.Err)
+		require.Equal(t, []byte("new secret value"), event.Value)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "no event for updated value")
+	}
+
+	// Removed value.
+	require.NoError(t, service.Delete(ctx, key))
+	select {
+	case event = <-ch:
+		require.EqualError(t, event.Err, majordomo.ErrNotFound.Error())
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "no event for removed value")
+	}
+
+	// Missing directory.
+	_, err = service.Watch(ctx, fmt.Sprintf("file://%s/missing/secret-key", base))
+	require.EqualError(t, err, majordomo.ErrNotFound.Error())
+}
+
+func TestFetchStream(t *testing.T) {
+	base, err := ioutil.TempDir("", "TestFetchStream")
+	require.NoError(t, err)
+	defer os.RemoveAll(base)
+	secretPath := filepath.Join(base, "secret-key")
+	require.NoError(t, ioutil.WriteFile(secretPath, []byte("secret value"), 0600))
+	key := fmt.Sprintf("file://%s", secretPath)
+
+	ctx := context.Background()
+	service, err := standard.New(ctx)
+	require.NoError(t, err)
+	confidant, err := file.New(ctx, file.WithLogLevel(zerolog.Disabled))
+	require.NoError(t, err)
+	require.NoError(t, service.RegisterConfidant(ctx, confidant))
+
+	reader, err := service.FetchStream(ctx, key)
+	require.NoError(t, err)
+	value, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	require.Equal(t, []byte("secret value"), value)
+
+	_, err = service.FetchStream(ctx, fmt.Sprintf("file://%s2", secretPath))
+	require.EqualError(t, err, majordomo.ErrNotFound.Error())
+
+	// Value too large.
+	service, err = standard.New(ctx, standard.WithMaxStreamSize(5))
+	require.NoError(t, err)
+	require.NoError(t, service.RegisterConfidant(ctx, confidant))
+	_, err = service.FetchStream(ctx, key)
+	require.EqualError(t, err, majordomo.ErrValueTooLarge.Error())
+}
+
+func TestPing(t *testing.T) {
+	base, err := ioutil.TempDir("", "TestPing")
+	require.NoError(t, err)
+	defer os.RemoveAll(base)
+
+	ctx := context.Background()
+
+	// No probe path.
+	confidant, err := file.New(ctx, file.WithLogLevel(zerolog.Disabled))
+	require.NoError(t, err)
+	require.NoError(t, confidant.Ping(ctx))
+
+	// Good probe path.
+	confidant, err = file.New(ctx, file.WithLogLevel(zerolog.Disabled), file.WithProbePath(base))
+	require.NoError(t, err)
+	require.NoError(t, confidant.Ping(ctx))
+
+	// Missing probe path.
+	confidant, err = file.New(ctx, file.WithLogLevel(zerolog.Disabled), file.WithProbePath(filepath.Join(base, "missing")))
+	require.NoError(t, err)
+	require.ErrorIs(t, confidant.Ping(ctx), majordomo.ErrNotFound)
+}
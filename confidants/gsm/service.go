@@ -16,16 +16,29 @@ package gsm
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"net/url"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 	zerologger "github.com/rs/zerolog/log"
 	"github.com/wealdtech/go-majordomo"
+	"github.com/wealdtech/go-majordomo/internal/memory"
+	"github.com/wealdtech/go-majordomo/internal/poll"
+	"github.com/wealdtech/go-majordomo/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 	secretmanagerpb "google.golang.org/genproto/googleapis/cloud/secretmanager/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // Service returns values from Google secrets manager.
@@ -36,11 +49,28 @@ import (
 // If both are supplied URLs are of the form "gsm:///secret".
 // Any provision of ID or of project will override the defaults.
 // N.B. the project value is the project _ID_ not the project name.
+// By default the latest version of the secret is returned.  A specific
+// version can be selected by number or by alias with the "version" query
+// parameter, for example "gsm://project/secret?version=7".
+// The service holds a single client connection for its lifetime, which is
+// released when the service is closed.
 type Service struct {
 	credentialsPath string
 	project         string
+	watchInterval   time.Duration
+	tracer          trace.Tracer
+
+	clientMu sync.Mutex
+	client   *secretmanager.Client
+	closed   bool
 }
 
+// versionRegex matches valid version selectors: either a version number or an alias.
+var versionRegex = regexp.MustCompile(`^(?:[1-9][0-9]*|[a-zA-Z][a-zA-Z0-9_-]{0,62})$`)
+
+// errClosed is returned when a request is made after the service has been closed.
+var errClosed = majordomo.NewError(majordomo.ErrUnavailable, errors.New("confidant closed"))
+
 // module-wide log.
 var log zerolog.Logger
 
@@ -60,6 +90,8 @@ func New(ctx context.Context, params ...Parameter) (*Service, error) {
 	s := &Service{
 		credentialsPath: parameters.credentialsPath,
 		project:         parameters.project,
+		watchInterval:   parameters.watchInterval,
+		tracer:          tracing.Tracer(parameters.tracerProvider),
 	}
 
 	return s, nil
@@ -72,36 +104,388 @@ func (s *Service) SupportedURLSchemes(ctx context.Context) ([]string, error) {
 
 // Fetch fetches a value given its key.
 func (s *Service) Fetch(ctx context.Context, url *url.URL) ([]byte, error) {
-	if url.Host == "" {
-		url.Host = s.project
+	ctx, span := tracing.Start(ctx, s.tracer, "confidant.Fetch", url.Scheme, "gsm", trace.WithSpanKind(trace.SpanKindClient))
+	value, err := s.fetch(ctx, url)
+	tracing.End(span, err)
+	return value, err
+}
+
+// fetch fetches a value given its key.
+func (s *Service) fetch(ctx context.Context, url *url.URL) ([]byte, error) {
+	project, secret, err := s.parseURL(url)
+	if err != nil {
+		return nil, err
 	}
-	if url.Host == "" {
-		return nil, errors.New("no project specified")
+	version, err := parseVersion(url)
+	if err != nil {
+		return nil, err
 	}
 
-	url.Path = strings.TrimPrefix(url.Path, "/")
-	if url.Path == "" {
-		return nil, errors.New("no secret specified")
+	client, err := s.obtainClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.accessSecretVersion(ctx, client, project, secret, version)
+	if err != nil {
+		return nil, err
+	}
+
+	return payloadData(resp), nil
+}
+
+// FetchWithMetadata fetches a value and its metadata given its key.
+// Metadata other than the version requires the secretmanager.versions.get and
+// secretmanager.secrets.get permissions; if the caller does not have them the
+// metadata they provide is omitted.
+func (s *Service) FetchWithMetadata(ctx context.Context, url *url.URL) ([]byte, *majordomo.Metadata, error) {
+	project, secret, err := s.parseURL(url)
+	if err != nil {
+		return nil, nil, err
+	}
+	version, err := parseVersion(url)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client, err := s.obtainClient(ctx)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	client, err := secretmanager.NewClient(ctx, option.WithCredentialsFile(s.credentialsPath))
+	resp, err := s.accessSecretVersion(ctx, client, project, secret, version)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to create client connection")
+		return nil, nil, err
+	}
+
+	// resp.Name contains the resolved version.
+	metadata := &majordomo.Metadata{
+		ID:         resp.Name,
+		Version:    resp.Name[strings.LastIndex(resp.Name, "/")+1:],
+		Attributes: make(map[string]string),
+	}
+
+	// Further metadata requires permissions beyond those required to access
+	// the secret, so is omitted if the caller does not have them.
+	versionInfo, err := client.GetSecretVersion(ctx, &secretmanagerpb.GetSecretVersionRequest{
+		Name: resp.Name,
+	})
+	switch {
+	case err == nil:
+		if versionInfo.CreateTime != nil {
+			metadata.Created = versionInfo.CreateTime.AsTime()
+		}
+		metadata.Attributes["state"] = versionInfo.State.String()
+	case status.Code(err) == codes.PermissionDenied:
+		log.Debug().Err(err).Msg("Not permitted to obtain secret version metadata")
+	default:
+		memory.Zero(payloadData(resp))
+		return nil, nil, gsmError(err, "failed to obtain secret version metadata")
+	}
+	secretInfo, err := client.GetSecret(ctx, &secretmanagerpb.GetSecretRequest{
+		Name: fmt.Sprintf("projects/%s/secrets/%s", project, secret),
+	})
+	switch {
+	case err == nil:
+		if secretInfo.GetExpireTime() != nil {
+			metadata.Expires = secretInfo.GetExpireTime().AsTime()
+		}
+		for alias, aliasVersion := range secretInfo.VersionAliases {
+			if fmt.Sprintf("%d", aliasVersion) == metadata.Version {
+				metadata.VersionStages = append(metadata.VersionStages, alias)
+			}
+		}
+		for k, v := range secretInfo.Labels {
+			metadata.Attributes[fmt.Sprintf("label:%s", k)] = v
+		}
+	case status.Code(err) == codes.PermissionDenied:
+		log.Debug().Err(err).Msg("Not permitted to obtain secret metadata")
+	default:
+		memory.Zero(payloadData(resp))
+		return nil, nil, gsmError(err, "failed to obtain secret metadata")
 	}
-	defer client.Close()
 
-	path := fmt.Sprintf("projects/%s/secrets/%s/versions/latest", url.Host, url.Path)
+	return payloadData(resp), metadata, nil
+}
+
+// accessSecretVersion accesses the given version of the secret.
+func (s *Service) accessSecretVersion(ctx context.Context, client *secretmanager.Client, project string, secret string, version string) (*secretmanagerpb.AccessSecretVersionResponse, error) {
+	path := fmt.Sprintf("projects/%s/secrets/%s/versions/%s", project, secret, version)
 	log.Trace().Str("path", path).Msg("Secret path")
 	req := &secretmanagerpb.AccessSecretVersionRequest{
 		Name: path,
 	}
 	resp, err := client.AccessSecretVersion(ctx, req)
 	if err != nil {
-		if strings.Contains(err.Error(), "it may not exist") {
-			return nil, majordomo.ErrNotFound
+		return nil, gsmError(err, "failed to fetch secret")
+	}
+
+	return resp, nil
+}
+
+// Store stores a value given its key as a new version of the secret,
+// creating the secret with automatic replication if it does not exist.
+func (s *Service) Store(ctx context.Context, url *url.URL, value []byte) error {
+	project, secret, err := s.parseURL(url)
+	if err != nil {
+		return err
+	}
+
+	client, err := s.obtainClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	path := fmt.Sprintf("projects/%s/secrets/%s", project, secret)
+	log.Trace().Str("path", path).Msg("Secret path")
+	req := &secretmanagerpb.AddSecretVersionRequest{
+		Parent: path,
+		Payload: &secretmanagerpb.SecretPayload{
+			Data: value,
+		},
+	}
+	_, err = client.AddSecretVersion(ctx, req)
+	if err == nil {
+		return nil
+	}
+	if status.Code(err) != codes.NotFound {
+		return gsmError(err, "failed to store secret")
+	}
+
+	// Secret does not exist; create it and try again.
+	log.Trace().Str("path", path).Msg("Secret not found; creating")
+	_, err = client.CreateSecret(ctx, &secretmanagerpb.CreateSecretRequest{
+		Parent:   fmt.Sprintf("projects/%s", project),
+		SecretId: secret,
+		Secret: &secretmanagerpb.Secret{
+			Replication: &secretmanagerpb.Replication{
+				Replication: &secretmanagerpb.Replication_Automatic_{
+					Automatic: &secretmanagerpb.Replication_Automatic{},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return gsmError(err, "failed to create secret")
+	}
+	if _, err := client.AddSecretVersion(ctx, req); err != nil {
+		return gsmError(err, "failed to store secret")
+	}
+
+	return nil
+}
+
+// Delete deletes a secret, including all of its versions, given its key.
+func (s *Service) Delete(ctx context.Context, url *url.URL) error {
+	project, secret, err := s.parseURL(url)
+	if err != nil {
+		return err
+	}
+
+	client, err := s.obtainClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	path := fmt.Sprintf("projects/%s/secrets/%s", project, secret)
+	log.Trace().Str("path", path).Msg("Secret path")
+	err = client.DeleteSecret(ctx, &secretmanagerpb.DeleteSecretRequest{
+		Name: path,
+	})
+	if err != nil {
+		return gsmError(err, "failed to delete secret")
+	}
+
+	return nil
+}
+
+// List lists the secrets whose names start with the prefix given in the URL.
+// A URL of the form "gsm://project/prefix" will list the secrets in the given
+// project whose names start with "prefix".
+func (s *Service) List(ctx context.Context, prefix *url.URL, pageToken string, pageSize int) ([]string, string, error) {
+	project, err := s.parseProject(prefix)
+	if err != nil {
+		return nil, "", err
+	}
+	namePrefix := strings.TrimPrefix(prefix.Path, "/")
+
+	client, err := s.obtainClient(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	req := &secretmanagerpb.ListSecretsRequest{
+		Parent: fmt.Sprintf("projects/%s", project),
+	}
+	if namePrefix != "" {
+		req.Filter = fmt.Sprintf("name:%s", namePrefix)
+	}
+
+	secrets := make([]*secretmanagerpb.Secret, 0)
+	nextPageToken, err := iterator.NewPager(client.ListSecrets(ctx, req), pageSize, pageToken).NextPage(&secrets)
+	if err != nil {
+		return nil, "", gsmError(err, "failed to list secrets")
+	}
+
+	keys := make([]string, 0, len(secrets))
+	for _, secret := range secrets {
+		// Secret names are of the form "projects/<project>/secrets/<name>".
+		name := secret.Name[strings.LastIndex(secret.Name, "/")+1:]
+		// The name filter is a substring match, so confirm the prefix here.
+		if !strings.HasPrefix(name, namePrefix) {
+			continue
+		}
+		keys = append(keys, fmt.Sprintf("gsm://%s/%s", project, name))
+	}
+
+	return keys, nextPageToken, nil
+}
+
+// Ping checks that the credentials are valid by obtaining an access token.
+func (s *Service) Ping(ctx context.Context) error {
+	var creds *google.Credentials
+	var err error
+	if s.credentialsPath != "" {
+		data, err := ioutil.ReadFile(s.credentialsPath)
+		if err != nil {
+			return errors.Wrap(err, "failed to read credentials")
+		}
+		creds, err = google.CredentialsFromJSON(ctx, data, secretmanager.DefaultAuthScopes()...)
+		if err != nil {
+			return majordomo.NewError(majordomo.ErrPermissionDenied, errors.Wrap(err, "invalid credentials"))
+		}
+	} else {
+		creds, err = google.FindDefaultCredentials(ctx, secretmanager.DefaultAuthScopes()...)
+		if err != nil {
+			return majordomo.NewError(majordomo.ErrPermissionDenied, errors.Wrap(err, "failed to obtain credentials"))
+		}
+	}
+
+	if _, err := creds.TokenSource.Token(); err != nil {
+		var retrieveErr *oauth2.RetrieveError
+		if errors.As(err, &retrieveErr) {
+			// The token server responded, so the credentials were rejected.
+			return majordomo.NewError(majordomo.ErrPermissionDenied, errors.Wrap(err, "failed to obtain access token"))
+		}
+		return majordomo.NewError(majordomo.ErrUnavailable, errors.Wrap(err, "failed to obtain access token"))
+	}
+
+	return nil
+}
+
+// payloadData detaches the data from the response, so that the caller holds the
+// only reference to it and can zero it when it is no longer required.
+func payloadData(resp *secretmanagerpb.AccessSecretVersionResponse) []byte {
+	if resp.Payload == nil {
+		return nil
+	}
+	data := resp.Payload.Data
+	resp.Payload.Data = nil
+	return data
+}
+
+// parseURL obtains the project and secret from the URL, applying defaults where required.
+func (s *Service) parseURL(url *url.URL) (string, string, error) {
+	project, err := s.parseProject(url)
+	if err != nil {
+		return "", "", err
+	}
+
+	url.Path = strings.TrimPrefix(url.Path, "/")
+	if url.Path == "" {
+		return "", "", errors.New("no secret specified")
+	}
+
+	return project, url.Path, nil
+}
+
+// parseVersion obtains the version selector from the URL, defaulting to the latest version.
+// A version selector is either a version number or a version alias.
+func parseVersion(url *url.URL) (string, error) {
+	versions, exists := url.Query()["version"]
+	if !exists {
+		return "latest", nil
+	}
+	if len(versions) != 1 || !versionRegex.MatchString(versions[0]) {
+		return "", majordomo.ErrURLInvalid
+	}
+
+	return versions[0], nil
+}
+
+// parseProject obtains the project from the URL, applying the default if required.
+func (s *Service) parseProject(url *url.URL) (string, error) {
+	if url.Host == "" {
+		url.Host = s.project
+	}
+	if url.Host == "" {
+		return "", errors.New("no project specified")
+	}
+
+	return url.Host, nil
+}
+
+// obtainClient obtains the client for Google secrets manager, creating it if required.
+func (s *Service) obtainClient(ctx context.Context) (*secretmanager.Client, error) {
+	s.clientMu.Lock()
+	defer s.clientMu.Unlock()
+
+	if s.closed {
+		return nil, errClosed
+	}
+	if s.client == nil {
+		// The client outlives the request, so it must not be bound to the request's context.
+		client, err := secretmanager.NewClient(context.Background(), option.WithCredentialsFile(s.credentialsPath))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create client connection")
 		}
-		return nil, errors.Wrap(err, "failed to fetch secret")
+		s.client = client
 	}
 
-	return resp.Payload.Data, nil
+	return s.client, nil
+}
+
+// Close closes the client connection.
+// Requests that are in progress when the service is closed may fail, and
+// subsequent requests will fail.
+func (s *Service) Close(ctx context.Context) error {
+	s.clientMu.Lock()
+	defer s.clientMu.Unlock()
+
+	s.closed = true
+	if s.client == nil {
+		return nil
+	}
+	client := s.client
+	s.client = nil
+	if err := client.Close(); err != nil {
+		return errors.Wrap(err, "failed to close client connection")
+	}
+
+	return nil
+}
+
+// Watch watches a value given its key, polling for changes at the configured interval.
+func (s *Service) Watch(ctx context.Context, url *url.URL) (<-chan *majordomo.WatchEvent, error) {
+	return poll.Watch(ctx, s.watchInterval, func(ctx context.Context) ([]byte, error) {
+		return s.Fetch(ctx, url)
+	}), nil
+}
+
+// gsmError maps Google secrets manager errors to majordomo well-known errors where possible.
+func gsmError(err error, msg string) error {
+	switch status.Code(err) {
+	case codes.NotFound:
+		return majordomo.ErrNotFound
+	case codes.PermissionDenied, codes.Unauthenticated:
+		return majordomo.NewError(majordomo.ErrPermissionDenied, errors.Wrap(err, msg))
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted, codes.Internal:
+		return majordomo.NewError(majordomo.ErrUnavailable, errors.Wrap(err, msg))
+	case codes.DeadlineExceeded:
+		return majordomo.NewError(majordomo.ErrTimeout, errors.Wrap(err, msg))
+	case codes.InvalidArgument:
+		return majordomo.NewError(majordomo.ErrURLInvalid, errors.Wrap(err, msg))
+	default:
+		return errors.Wrap(err, msg)
+	}
 }
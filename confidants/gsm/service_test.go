@@ -15,15 +15,25 @@ package gsm_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"os"
 	"testing"
 
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
 	"github.com/wealdtech/go-majordomo"
 	"github.com/wealdtech/go-majordomo/confidants/gsm"
 	"github.com/wealdtech/go-majordomo/standard"
+	"google.golang.org/api/option"
+	secretmanagerpb "google.golang.org/genproto/googleapis/cloud/secretmanager/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
 	"gotest.tools/assert"
 )
 
@@ -124,3 +134,209 @@ func TestOverrides(t *testing.T) {
 
 	assert.Equal(t, string(secret1), string(secret2))
 }
+
+func TestInvalidVersion(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{
+			name: "Empty",
+			key:  "gsm://project/secret?version=",
+		},
+		{
+			name: "Zero",
+			key:  "gsm://project/secret?version=0",
+		},
+		{
+			name: "Negative",
+			key:  "gsm://project/secret?version=-1",
+		},
+		{
+			name: "InvalidCharacters",
+			key:  "gsm://project/secret?version=a/b",
+		},
+		{
+			name: "Multiple",
+			key:  "gsm://project/secret?version=1&version=2",
+		},
+	}
+
+	ctx := context.Background()
+	service, err := standard.New(ctx)
+	require.NoError(t, err)
+	confidant, err := gsm.New(ctx, gsm.WithLogLevel(zerolog.Disabled))
+	require.NoError(t, err)
+	require.NoError(t, service.RegisterConfidant(ctx, confidant))
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := service.Fetch(ctx, test.key)
+			require.EqualError(t, err, majordomo.ErrURLInvalid.Error())
+		})
+	}
+}
+
+func TestClose(t *testing.T) {
+	ctx := context.Background()
+	confidant, err := gsm.New(ctx, gsm.WithLogLevel(zerolog.Disabled))
+	require.NoError(t, err)
+	require.NoError(t, confidant.Close(ctx))
+
+	// Closing again is allowed.
+	require.NoError(t, confidant.Close(ctx))
+
+	service, err := standard.New(ctx)
+	require.NoError(t, err)
+	require.NoError(t, service.RegisterConfidant(ctx, confidant))
+	_, err = service.Fetch(ctx, "gsm://project/secret")
+	require.ErrorIs(t, err, majordomo.ErrUnavailable)
+}
+
+func TestGSMError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		kind error
+		msg  string
+	}{
+		{
+			name: "NotGRPC",
+			err:  errors.New("bad"),
+			msg:  "failed: bad",
+		},
+		{
+			name: "NotFound",
+			err:  status.Error(codes.NotFound, "not found"),
+			kind: majordomo.ErrNotFound,
+		},
+		{
+			name: "PermissionDenied",
+			err:  status.Error(codes.PermissionDenied, "denied"),
+			kind: majordomo.ErrPermissionDenied,
+			msg:  "permission denied: failed: rpc error: code = PermissionDenied desc = denied",
+		},
+		{
+			name: "Unauthenticated",
+			err:  status.Error(codes.Unauthenticated, "no credentials"),
+			kind: majordomo.ErrPermissionDenied,
+		},
+		{
+			name: "Unavailable",
+			err:  status.Error(codes.Unavailable, "unavailable"),
+			kind: majordomo.ErrUnavailable,
+		},
+		{
+			name: "ResourceExhausted",
+			err:  status.Error(codes.ResourceExhausted, "quota"),
+			kind: majordomo.ErrUnavailable,
+		},
+		{
+			name: "Aborted",
+			err:  status.Error(codes.Aborted, "aborted"),
+			kind: majordomo.ErrUnavailable,
+		},
+		{
+			name: "Internal",
+			err:  status.Error(codes.Internal, "internal"),
+			kind: majordomo.ErrUnavailable,
+		},
+		{
+			name: "DeadlineExceeded",
+			err:  status.Error(codes.DeadlineExceeded, "deadline"),
+			kind: majordomo.ErrTimeout,
+		},
+		{
+			name: "InvalidArgument",
+			err:  status.Error(codes.InvalidArgument, "invalid"),
+			kind: majordomo.ErrURLInvalid,
+		},
+		{
+			name: "Other",
+			err:  status.Error(codes.FailedPrecondition, "disabled"),
+			msg:  "failed: rpc error: code = FailedPrecondition desc = disabled",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := gsm.GSMError(test.err, "failed")
+			if test.kind != nil {
+				require.True(t, errors.Is(err, test.kind))
+			} else {
+				for _, kind := range []error{majordomo.ErrNotFound, majordomo.ErrPermissionDenied, majordomo.ErrUnavailable, majordomo.ErrTimeout, majordomo.ErrURLInvalid} {
+					require.False(t, errors.Is(err, kind))
+				}
+			}
+			if test.msg != "" {
+				require.EqualError(t, err, test.msg)
+			}
+		})
+	}
+}
+
+func TestFetchWithMetadataAccessorOnly(t *testing.T) {
+	ctx := context.Background()
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	secretmanagerpb.RegisterSecretManagerServiceServer(server, &MockSecretManagerServer{})
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer server.Stop()
+
+	conn, err := grpc.DialContext(ctx, "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.Dial()
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	client, err := secretmanager.NewClient(ctx, option.WithGRPCConn(conn))
+	require.NoError(t, err)
+
+	confidant, err := gsm.New(ctx, gsm.WithLogLevel(zerolog.Disabled))
+	require.NoError(t, err)
+	confidant.SetClient(client)
+	defer func() {
+		require.NoError(t, confidant.Close(ctx))
+	}()
+	service, err := standard.New(ctx)
+	require.NoError(t, err)
+	require.NoError(t, service.RegisterConfidant(ctx, confidant))
+
+	// Metadata that needs more than access to the secret is omitted.
+	value, metadata, err := service.FetchWithMetadata(ctx, "gsm://project/secret")
+	require.NoError(t, err)
+	require.Equal(t, []byte("secret value"), value)
+	require.Equal(t, "projects/project/secrets/secret/versions/3", metadata.ID)
+	require.Equal(t, "3", metadata.Version)
+	require.True(t, metadata.Created.IsZero())
+	require.Empty(t, metadata.Attributes)
+}
+
+// MockSecretManagerServer is a secret manager server that only allows
+// secrets to be accessed, as per the secretAccessor role.
+type MockSecretManagerServer struct {
+	secretmanagerpb.UnimplementedSecretManagerServiceServer
+}
+
+// AccessSecretVersion returns the latest version of the secret.
+func (s *MockSecretManagerServer) AccessSecretVersion(ctx context.Context, req *secretmanagerpb.AccessSecretVersionRequest) (*secretmanagerpb.AccessSecretVersionResponse, error) {
+	return &secretmanagerpb.AccessSecretVersionResponse{
+		Name: "projects/project/secrets/secret/versions/3",
+		Payload: &secretmanagerpb.SecretPayload{
+			Data: []byte("secret value"),
+		},
+	}, nil
+}
+
+// GetSecretVersion is not permitted.
+func (s *MockSecretManagerServer) GetSecretVersion(ctx context.Context, req *secretmanagerpb.GetSecretVersionRequest) (*secretmanagerpb.SecretVersion, error) {
+	return nil, status.Error(codes.PermissionDenied, "permission denied")
+}
+
+// GetSecret is not permitted.
+func (s *MockSecretManagerServer) GetSecret(ctx context.Context, req *secretmanagerpb.GetSecretRequest) (*secretmanagerpb.Secret, error) {
+	return nil, status.Error(codes.PermissionDenied, "permission denied")
+}
@@ -14,14 +14,22 @@
 package http
 
 import (
+	"time"
+
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type parameters struct {
-	logLevel   zerolog.Level
-	clientCert []byte
-	clientKey  []byte
-	caCert     []byte
+	logLevel       zerolog.Level
+	clientCert     []byte
+	clientKey      []byte
+	caCert         []byte
+	watchInterval  time.Duration
+	probeURL       string
+	maxSize        int64
+	tracerProvider trace.TracerProvider
 }
 
 // Parameter is the interface for service parameters.
@@ -63,10 +71,43 @@ func WithCACert(cert []byte) Parameter {
 	})
 }
 
+// WithWatchInterval sets the interval at which values are polled when watched.
+func WithWatchInterval(interval time.Duration) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.watchInterval = interval
+	})
+}
+
+// WithProbeURL sets the URL requested to check the health of the confidant.
+func WithProbeURL(probeURL string) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.probeURL = probeURL
+	})
+}
+
+// WithMaxSize sets the maximum size of values returned by the confidant.
+// Larger values return majordomo.ErrValueTooLarge.  A size of 0 means that
+// there is no limit.
+func WithMaxSize(size int64) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.maxSize = size
+	})
+}
+
+// WithTracerProvider sets the OpenTelemetry tracer provider used to create
+// spans.  If not supplied no spans are created.
+func WithTracerProvider(provider trace.TracerProvider) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.tracerProvider = provider
+	})
+}
+
 // parseAndCheckParameters parses and checks parameters to ensure that mandatory parameters are present and correct.
 func parseAndCheckParameters(params ...Parameter) (*parameters, error) {
 	parameters := parameters{
-		logLevel: zerolog.GlobalLevel(),
+		logLevel:      zerolog.GlobalLevel(),
+		watchInterval: time.Minute,
+		maxSize:       64 * 1024 * 1024,
 	}
 	for _, p := range params {
 		if params != nil {
@@ -74,5 +115,12 @@ func parseAndCheckParameters(params ...Parameter) (*parameters, error) {
 		}
 	}
 
+	if parameters.watchInterval <= 0 {
+		return nil, errors.New("watch interval must be greater than 0")
+	}
+	if parameters.maxSize < 0 {
+		return nil, errors.New("max size cannot be negative")
+	}
+
 	return &parameters, nil
 }
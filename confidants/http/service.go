@@ -16,17 +16,29 @@ package http
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/binary"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 	zerologger "github.com/rs/zerolog/log"
 	"github.com/wealdtech/go-majordomo"
+	"github.com/wealdtech/go-majordomo/internal/memory"
+	"github.com/wealdtech/go-majordomo/internal/poll"
+	"github.com/wealdtech/go-majordomo/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Service returns the values from an HTTP connection.
@@ -41,7 +53,22 @@ import (
 // - HTTPMethod the HTTP method, as a string (e.g. http.MethodPost)
 // - MIMEType the MIME type for request and response, as a string (e.g. application/json)
 // - Body the request body, as a byte slice
-type Service struct{}
+// Clients are created on demand for each set of TLS options, and held for the
+// lifetime of the service along with their connections, which are released
+// when the service is closed.
+type Service struct {
+	watchInterval time.Duration
+	probeURL      string
+	maxSize       int64
+	tracer        trace.Tracer
+	// propagate is true if trace context is propagated to the server.
+	propagate bool
+
+	clientsMu     sync.Mutex
+	defaultClient *http.Client
+	clients       map[[sha256.Size]byte]*http.Client
+	closed        bool
+}
 
 // CaCert is a context tag for the CA certificate.
 type CACert struct{}
@@ -61,6 +88,9 @@ type MIMEType struct{}
 // Body is a context tag for the request body.
 type Body struct{}
 
+// errClosed is returned when a request is made after the service has been closed.
+var errClosed = majordomo.NewError(majordomo.ErrUnavailable, errors.New("confidant closed"))
+
 // module-wide log.
 var log zerolog.Logger
 
@@ -77,7 +107,17 @@ func New(ctx context.Context, params ...Parameter) (*Service, error) {
 		log = log.Level(parameters.logLevel)
 	}
 
-	s := &Service{}
+	s := &Service{
+		watchInterval: parameters.watchInterval,
+		probeURL:      parameters.probeURL,
+		maxSize:       parameters.maxSize,
+		tracer:        tracing.Tracer(parameters.tracerProvider),
+		propagate:     parameters.tracerProvider != nil,
+		defaultClient: &http.Client{
+			Transport: http.DefaultTransport.(*http.Transport).Clone(),
+		},
+		clients: make(map[[sha256.Size]byte]*http.Client),
+	}
 
 	return s, nil
 }
@@ -89,50 +129,225 @@ func (s *Service) SupportedURLSchemes(ctx context.Context) ([]string, error) {
 
 // Fetch fetches a value given its https URL.
 func (s *Service) Fetch(ctx context.Context, url *url.URL) ([]byte, error) {
-	_, clientCertExists := ctx.Value(&ClientCert{}).([]byte)
-	_, httpMethodExists := ctx.Value(&HTTPMethod{}).(string)
-	_, mimeTypeExists := ctx.Value(&MIMEType{}).(string)
-	_, bodyExists := ctx.Value(&Body{}).([]byte)
-	if clientCertExists || httpMethodExists || mimeTypeExists || bodyExists {
-		return s.fetchWithOptions(ctx, url)
+	ctx, span := tracing.Start(ctx, s.tracer, "confidant.Fetch", url.Scheme, "http", trace.WithSpanKind(trace.SpanKindClient))
+	data, _, err := s.fetch(ctx, url)
+	tracing.End(span, err)
+	return data, err
+}
+
+// FetchWithMetadata fetches a value and its metadata given its https URL.
+// The metadata contains the last modified time of the value if supplied by
+// the server, and its ETag and content type as attributes.
+func (s *Service) FetchWithMetadata(ctx context.Context, url *url.URL) ([]byte, *majordomo.Metadata, error) {
+	data, header, err := s.fetch(ctx, url)
+	if err != nil {
+		return nil, nil, err
 	}
-	return s.fetch(ctx, url)
+
+	metadata := &majordomo.Metadata{
+		ID:         url.Redacted(),
+		Attributes: make(map[string]string),
+	}
+	if lastModified := header.Get("Last-Modified"); lastModified != "" {
+		if modified, err := http.ParseTime(lastModified); err == nil {
+			metadata.Modified = modified
+		}
+	}
+	if etag := header.Get("ETag"); etag != "" {
+		metadata.Attributes["etag"] = etag
+	}
+	if contentType := header.Get("Content-Type"); contentType != "" {
+		metadata.Attributes["content_type"] = contentType
+	}
+
+	return data, metadata, nil
 }
 
-func (s *Service) fetch(ctx context.Context, url *url.URL) ([]byte, error) {
-	resp, err := http.Get(url.String())
+// FetchStream fetches a value given its https URL, returning a reader for the value.
+// The value is limited to the smaller of maxSize and the service's maximum size.
+func (s *Service) FetchStream(ctx context.Context, url *url.URL, maxSize int64) (io.ReadCloser, error) {
+	if maxSize == 0 || (s.maxSize > 0 && s.maxSize < maxSize) {
+		maxSize = s.maxSize
+	}
+	resp, err := s.doRequest(ctx, url)
 	if err != nil {
-		log.Debug().Err(err).Msg("Failed to call endpoint")
-		return nil, majordomo.ErrNotFound
+		return nil, err
+	}
+
+	statusFamily := resp.StatusCode / 100
+	if statusFamily != 2 {
+		log.Debug().Int("status_code", resp.StatusCode).Msg("Request failed")
+		s.closeResponse(resp)
+		return nil, statusError(resp.StatusCode)
 	}
-	if resp == nil {
-		log.Debug().Err(err).Msg("No body returned for endpoint")
+	if resp.ContentLength == 0 {
+		log.Debug().Msg("No data in response")
+		s.closeResponse(resp)
 		return nil, majordomo.ErrNotFound
 	}
+	// Reject oversized responses up front where possible.
+	if maxSize > 0 && resp.ContentLength > maxSize {
+		s.closeResponse(resp)
+		return nil, majordomo.ErrValueTooLarge
+	}
+
+	return majordomo.NewLimitedReadCloser(resp.Body, maxSize), nil
+}
 
-	data, err := io.ReadAll(resp.Body)
-	if closeErr := resp.Body.Close(); closeErr != nil {
-		log.Debug().Err(closeErr).Msg("Response close() returned an error")
+func (s *Service) fetch(ctx context.Context, url *url.URL) ([]byte, http.Header, error) {
+	resp, err := s.doRequest(ctx, url)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Reject oversized responses up front where possible.
+	if s.maxSize > 0 && resp.ContentLength > s.maxSize {
+		s.closeResponse(resp)
+		return nil, nil, majordomo.ErrValueTooLarge
 	}
+	data, err := readAll(resp.Body, resp.ContentLength, s.maxSize)
+	s.closeResponse(resp)
 	if err != nil {
+		if errors.Is(err, majordomo.ErrValueTooLarge) {
+			return nil, nil, err
+		}
 		log.Debug().Err(err).Msg("Failed to read response")
-		return nil, majordomo.ErrNotFound
-	}
-	if len(data) == 0 {
-		log.Debug().Err(err).Msg("No data in response")
-		return nil, majordomo.ErrNotFound
+		return nil, nil, requestError(err)
 	}
 
 	statusFamily := resp.StatusCode / 100
 	if statusFamily != 2 {
 		log.Debug().Int("status_code", resp.StatusCode).Str("data", string(data)).Msg("Request failed")
-		return nil, majordomo.ErrNotFound
+		memory.Zero(data)
+		return nil, nil, statusError(resp.StatusCode)
 	}
 
-	return data, nil
+	if len(data) == 0 {
+		log.Debug().Msg("No data in response")
+		return nil, nil, majordomo.ErrNotFound
+	}
+
+	return data, resp.Header, nil
 }
 
-func (s *Service) fetchWithOptions(ctx context.Context, url *url.URL) ([]byte, error) {
+// doRequest carries out the request for the URL.
+func (s *Service) doRequest(ctx context.Context, url *url.URL) (*http.Response, error) {
+	client, err := s.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	httpMethod, httpMethodExists := ctx.Value(&HTTPMethod{}).(string)
+	if !httpMethodExists {
+		httpMethod = http.MethodGet
+	} else {
+		httpMethod = strings.ToUpper(httpMethod)
+	}
+
+	body, bodyExists := ctx.Value(&Body{}).([]byte)
+	var bodyReader io.Reader
+	if bodyExists {
+		bodyReader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, httpMethod, url.String(), bodyReader)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to create request")
+		return nil, majordomo.NewError(majordomo.ErrURLInvalid, err)
+	}
+
+	mimeType, mimeTypeExists := ctx.Value(&MIMEType{}).(string)
+	if mimeTypeExists {
+		req.Header.Set("Content-type", strings.ToLower(mimeType))
+		req.Header.Set("Accept", strings.ToLower(mimeType))
+	}
+	if s.propagate {
+		// Propagate the trace context to the server using the globally configured propagator.
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to call endpoint")
+		return nil, requestError(err)
+	}
+
+	return resp, nil
+}
+
+// client returns the client for the options supplied in the context,
+// creating it if required.
+func (s *Service) client(ctx context.Context) (*http.Client, error) {
+	_, clientCertExists := ctx.Value(&ClientCert{}).([]byte)
+	_, httpMethodExists := ctx.Value(&HTTPMethod{}).(string)
+	_, mimeTypeExists := ctx.Value(&MIMEType{}).(string)
+	_, bodyExists := ctx.Value(&Body{}).([]byte)
+
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
+
+	if s.closed {
+		return nil, errClosed
+	}
+	if !(clientCertExists || httpMethodExists || mimeTypeExists || bodyExists) {
+		return s.defaultClient, nil
+	}
+
+	key := optionsKey(ctx)
+	if client, exists := s.clients[key]; exists {
+		return client, nil
+	}
+	client, err := s.clientWithOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.clients[key] = client
+
+	return client, nil
+}
+
+// optionsKey returns a key for the TLS options supplied in the context.
+// The key is a hash, so that key material is not held for longer than required.
+func optionsKey(ctx context.Context) [sha256.Size]byte {
+	hash := sha256.New()
+	for _, tag := range []interface{}{&CACert{}, &ClientCert{}, &ClientKey{}} {
+		value, exists := ctx.Value(tag).([]byte)
+		if !exists {
+			hash.Write([]byte{0})
+			continue
+		}
+		// Include the length to avoid ambiguity between adjacent values.
+		length := make([]byte, 9)
+		length[0] = 1
+		binary.BigEndian.PutUint64(length[1:], uint64(len(value)))
+		hash.Write(length)
+		hash.Write(value)
+	}
+
+	var key [sha256.Size]byte
+	copy(key[:], hash.Sum(nil))
+	return key
+}
+
+// Close releases the clients and their connections.
+// Requests that are in progress when the service is closed may fail, and
+// subsequent requests will fail.
+func (s *Service) Close(ctx context.Context) error {
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
+
+	s.closed = true
+	s.defaultClient.CloseIdleConnections()
+	for _, client := range s.clients {
+		client.CloseIdleConnections()
+	}
+	s.clients = make(map[[sha256.Size]byte]*http.Client)
+
+	return nil
+}
+
+// clientWithOptions creates a client with the TLS options supplied in the context.
+func (s *Service) clientWithOptions(ctx context.Context) (*http.Client, error) {
 	caCert, caCertExists := ctx.Value(&CACert{}).([]byte)
 	clientCert, clientCertExists := ctx.Value(&ClientCert{}).([]byte)
 	clientKey, clientKeyExists := ctx.Value(&ClientKey{}).([]byte)
@@ -156,67 +371,121 @@ func (s *Service) fetchWithOptions(ctx context.Context, url *url.URL) ([]byte, e
 		}
 		tlsConfig.Certificates = []tls.Certificate{cert}
 	}
-	client := &http.Client{
+
+	return &http.Client{
 		Transport: &http.Transport{
 			TLSClientConfig: tlsConfig,
 		},
-	}
+	}, nil
+}
 
-	httpMethod, httpMethodExists := ctx.Value(&HTTPMethod{}).(string)
-	if !httpMethodExists {
-		httpMethod = http.MethodGet
-	} else {
-		httpMethod = strings.ToUpper(httpMethod)
+// closeResponse closes the response body.
+func (s *Service) closeResponse(resp *http.Response) {
+	if err := resp.Body.Close(); err != nil {
+		log.Debug().Err(err).Msg("Response close() returned an error")
 	}
+}
 
-	body, bodyExists := ctx.Value(&Body{}).([]byte)
-	var bodyReader io.Reader
-	if bodyExists {
-		bodyReader = bytes.NewReader(body)
+// Watch watches a value given its key, polling for changes at the configured interval.
+func (s *Service) Watch(ctx context.Context, url *url.URL) (<-chan *majordomo.WatchEvent, error) {
+	return poll.Watch(ctx, s.watchInterval, func(ctx context.Context) ([]byte, error) {
+		return s.Fetch(ctx, url)
+	}), nil
+}
+
+// Ping checks that the probe URL, if configured, responds successfully to a HEAD request.
+func (s *Service) Ping(ctx context.Context) error {
+	if s.probeURL == "" {
+		return nil
 	}
 
-	req, err := http.NewRequestWithContext(ctx, httpMethod, url.String(), bodyReader)
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, s.probeURL, nil)
 	if err != nil {
-		log.Debug().Err(err).Msg("Failed to create request")
-		return nil, majordomo.ErrNotFound
+		return majordomo.NewError(majordomo.ErrURLInvalid, err)
 	}
-
-	mimeType, mimeTypeExists := ctx.Value(&MIMEType{}).(string)
-	if mimeTypeExists {
-		req.Header.Set("Content-type", strings.ToLower(mimeType))
-		req.Header.Set("Accept", strings.ToLower(mimeType))
+	client, err := s.client(ctx)
+	if err != nil {
+		return err
 	}
-
 	resp, err := client.Do(req)
 	if err != nil {
-		log.Debug().Msg("Failed to call endpoint")
-		return nil, majordomo.ErrNotFound
+		return requestError(err)
 	}
-	if resp == nil {
-		log.Debug().Msg("No body returned for endpoint")
-		return nil, majordomo.ErrNotFound
+	if err := resp.Body.Close(); err != nil {
+		log.Debug().Err(err).Msg("Response close() returned an error")
 	}
-
-	data, err := io.ReadAll(resp.Body)
-	if closeErr := resp.Body.Close(); closeErr != nil {
-		log.Debug().Err(closeErr).Msg("Response close() returned an error")
+	if resp.StatusCode/100 != 2 {
+		return statusError(resp.StatusCode)
 	}
-	if err != nil {
-		log.Debug().Err(err).Msg("Failed to read response")
-		return nil, majordomo.ErrNotFound
+
+	return nil
+}
+
+// maxSizeHint is the largest size hint used to size the buffer in readAll,
+// so that a server cannot force a large allocation by claiming a large size.
+const maxSizeHint = 64 * 1024
+
+// readAll reads all data from the reader, returning majordomo.ErrValueTooLarge
+// if there is more than maxSize bytes of data; a maxSize of 0 means that there
+// is no limit.
+// Unlike io.ReadAll, buffers that are outgrown are zeroed rather than being
+// left in memory.  If the size of the data is known it is used to size the
+// buffer up front, up to maxSizeHint.
+func readAll(r io.Reader, sizeHint int64, maxSize int64) ([]byte, error) {
+	size := 512
+	if sizeHint > 0 && sizeHint < maxSizeHint {
+		// Allow an extra byte so that EOF can be read without growing the buffer.
+		size = int(sizeHint) + 1
+	} else if sizeHint >= maxSizeHint {
+		size = maxSizeHint
 	}
-	if len(data) == 0 {
-		log.Debug().Err(err).Msg("No data in response")
-		return nil, majordomo.ErrNotFound
+	data := make([]byte, 0, size)
+	for {
+		if len(data) == cap(data) {
+			grown := make([]byte, len(data), 2*cap(data))
+			copy(grown, data)
+			memory.Zero(data)
+			data = grown
+		}
+		n, err := r.Read(data[len(data):cap(data)])
+		data = data[:len(data)+n]
+		if maxSize > 0 && int64(len(data)) > maxSize {
+			memory.Zero(data)
+			return nil, majordomo.ErrValueTooLarge
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return data, nil
+			}
+			memory.Zero(data)
+			return nil, err
+		}
 	}
-	// Because we are using our own client for this call we close it here to avoid connection leaks.
-	client.CloseIdleConnections()
+}
 
-	statusFamily := resp.StatusCode / 100
-	if statusFamily != 2 {
-		log.Debug().Int("status_code", resp.StatusCode).Str("data", string(data)).Msg("Request failed")
-		return nil, majordomo.ErrNotFound
+// statusError maps HTTP status codes to majordomo well-known errors.
+// Status codes without a well-known error return a generic error.
+func statusError(statusCode int) error {
+	err := fmt.Errorf("request failed with status %d", statusCode)
+	switch {
+	case statusCode == http.StatusNotFound, statusCode == http.StatusGone:
+		return majordomo.ErrNotFound
+	case statusCode == http.StatusUnauthorized, statusCode == http.StatusForbidden:
+		return majordomo.NewError(majordomo.ErrPermissionDenied, err)
+	case statusCode == http.StatusRequestTimeout, statusCode == http.StatusGatewayTimeout:
+		return majordomo.NewError(majordomo.ErrTimeout, err)
+	case statusCode == http.StatusTooManyRequests, statusCode/100 == 5:
+		return majordomo.NewError(majordomo.ErrUnavailable, err)
+	default:
+		return err
 	}
+}
 
-	return data, nil
+// requestError maps errors from HTTP requests to majordomo well-known errors.
+func requestError(err error) error {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return majordomo.NewError(majordomo.ErrTimeout, err)
+	}
+	return majordomo.NewError(majordomo.ErrUnavailable, err)
 }
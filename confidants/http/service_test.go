@@ -16,7 +16,9 @@ package http_test
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -25,6 +27,11 @@ import (
 	httpconfidant "github.com/wealdtech/go-majordomo/confidants/http"
 	"github.com/wealdtech/go-majordomo/standard"
 	"github.com/wealdtech/go-majordomo/testing/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestFetch(t *testing.T) {
@@ -34,6 +41,18 @@ func TestFetch(t *testing.T) {
 	})
 	http.HandleFunc("/nodata", func(w http.ResponseWriter, r *http.Request) {
 	})
+	http.HandleFunc("/forbidden", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+	http.HandleFunc("/unavailable", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	http.HandleFunc("/gone", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	})
+	http.HandleFunc("/badrequest", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	})
 
 	// listen to port
 	go func() {
@@ -48,6 +67,7 @@ func TestFetch(t *testing.T) {
 		key        string
 		value      []byte
 		err        string
+		errIs      error
 		logEntries []string
 		ctxMap     map[interface{}]interface{}
 	}{
@@ -56,9 +76,9 @@ func TestFetch(t *testing.T) {
 			err:  majordomo.ErrNotFound.Error(),
 		},
 		{
-			name: "BadHostname",
-			key:  "http://bad_hostname/",
-			err:  majordomo.ErrNotFound.Error(),
+			name:  "BadHostname",
+			key:   "http://bad_hostname/",
+			errIs: majordomo.ErrUnavailable,
 			logEntries: []string{
 				`Failed to call endpoint`,
 			},
@@ -82,6 +102,38 @@ func TestFetch(t *testing.T) {
 				`Request failed`,
 			},
 		},
+		{
+			name:  "Forbidden",
+			key:   "http://localhost:5050/forbidden",
+			errIs: majordomo.ErrPermissionDenied,
+			logEntries: []string{
+				`Request failed`,
+			},
+		},
+		{
+			name:  "Unavailable",
+			key:   "http://localhost:5050/unavailable",
+			errIs: majordomo.ErrUnavailable,
+			logEntries: []string{
+				`Request failed`,
+			},
+		},
+		{
+			name: "Gone",
+			key:  "http://localhost:5050/gone",
+			err:  majordomo.ErrNotFound.Error(),
+			logEntries: []string{
+				`Request failed`,
+			},
+		},
+		{
+			name: "BadRequest",
+			key:  "http://localhost:5050/badrequest",
+			err:  "request failed with status 400",
+			logEntries: []string{
+				`Request failed`,
+			},
+		},
 		{
 			name: "NoData",
 			key:  "http://localhost:5050/nodata",
@@ -141,8 +193,12 @@ func TestFetch(t *testing.T) {
 			require.NoError(t, service.RegisterConfidant(ctx, confidant))
 
 			value, err := service.Fetch(ctx, test.key)
-			if test.err != "" {
-				require.EqualError(t, err, test.err)
+			if test.err != "" || test.errIs != nil {
+				if test.err != "" {
+					require.EqualError(t, err, test.err)
+				} else {
+					require.ErrorIs(t, err, test.errIs)
+				}
 				for _, logEntry := range test.logEntries {
 					if !capture.HasLog(map[string]interface{}{
 						"message": logEntry,
@@ -157,3 +213,215 @@ func TestFetch(t *testing.T) {
 		})
 	}
 }
+
+func TestFetchWithMetadata(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"abc123"`)
+		w.Header().Set("Last-Modified", "Wed, 21 Oct 2015 07:28:00 GMT")
+		fmt.Fprintf(w, "response 1")
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	service, err := standard.New(ctx)
+	require.NoError(t, err)
+	confidant, err := httpconfidant.New(ctx)
+	require.NoError(t, err)
+	require.NoError(t, service.RegisterConfidant(ctx, confidant))
+
+	value, metadata, err := service.FetchWithMetadata(ctx, server.URL)
+	require.NoError(t, err)
+	require.Equal(t, []byte("response 1"), value)
+	require.Equal(t, server.URL, metadata.ID)
+	require.Equal(t, `"abc123"`, metadata.Attributes["etag"])
+	require.Equal(t, time.Date(2015, 10, 21, 7, 28, 0, 0, time.UTC), metadata.Modified)
+}
+
+func TestFetchStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/chunked":
+			// Flushing before writing forces a chunked response without a content length.
+			w.(http.Flusher).Flush()
+			fmt.Fprintf(w, "chunked response")
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			fmt.Fprintf(w, "response 1")
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	service, err := standard.New(ctx, standard.WithMaxStreamSize(12))
+	require.NoError(t, err)
+	confidant, err := httpconfidant.New(ctx)
+	require.NoError(t, err)
+	require.NoError(t, service.RegisterConfidant(ctx, confidant))
+
+	reader, err := service.FetchStream(ctx, fmt.Sprintf("%s/path1", server.URL))
+	require.NoError(t, err)
+	value, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	require.Equal(t, []byte("response 1"), value)
+
+	_, err = service.FetchStream(ctx, fmt.Sprintf("%s/missing", server.URL))
+	require.EqualError(t, err, majordomo.ErrNotFound.Error())
+
+	// Value too large, detected while reading.
+	reader, err = service.FetchStream(ctx, fmt.Sprintf("%s/chunked", server.URL))
+	require.NoError(t, err)
+	_, err = io.ReadAll(reader)
+	require.EqualError(t, err, majordomo.ErrValueTooLarge.Error())
+	require.NoError(t, reader.Close())
+}
+
+func TestFetchMaxSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/chunked":
+			// Flushing before writing forces a chunked response without a content length.
+			w.(http.Flusher).Flush()
+			fmt.Fprintf(w, "chunked response")
+		case "/large":
+			fmt.Fprintf(w, "large response")
+		default:
+			fmt.Fprintf(w, "response 1")
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	_, err := httpconfidant.New(ctx, httpconfidant.WithMaxSize(-1))
+	require.EqualError(t, err, "problem with parameters: max size cannot be negative")
+
+	service, err := standard.New(ctx)
+	require.NoError(t, err)
+	confidant, err := httpconfidant.New(ctx, httpconfidant.WithMaxSize(12))
+	require.NoError(t, err)
+	require.NoError(t, service.RegisterConfidant(ctx, confidant))
+
+	value, err := service.Fetch(ctx, fmt.Sprintf("%s/path1", server.URL))
+	require.NoError(t, err)
+	require.Equal(t, []byte("response 1"), value)
+
+	// Value too large, detected from the content length.
+	_, err = service.Fetch(ctx, fmt.Sprintf("%s/large", server.URL))
+	require.ErrorIs(t, err, majordomo.ErrValueTooLarge)
+
+	// Value too large, detected while reading.
+	_, err = service.Fetch(ctx, fmt.Sprintf("%s/chunked", server.URL))
+	require.ErrorIs(t, err, majordomo.ErrValueTooLarge)
+
+	// The service's maximum size also applies to streams.
+	reader, err := service.FetchStream(ctx, fmt.Sprintf("%s/chunked", server.URL))
+	require.NoError(t, err)
+	_, err = io.ReadAll(reader)
+	require.EqualError(t, err, majordomo.ErrValueTooLarge.Error())
+	require.NoError(t, reader.Close())
+}
+
+func TestPing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" || r.Method != http.MethodHead {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+
+	// No probe URL.
+	confidant, err := httpconfidant.New(ctx)
+	require.NoError(t, err)
+	require.NoError(t, confidant.Ping(ctx))
+
+	// Good probe URL.
+	confidant, err = httpconfidant.New(ctx, httpconfidant.WithProbeURL(fmt.Sprintf("%s/health", server.URL)))
+	require.NoError(t, err)
+	require.NoError(t, confidant.Ping(ctx))
+
+	// Failing probe URL.
+	confidant, err = httpconfidant.New(ctx, httpconfidant.WithProbeURL(fmt.Sprintf("%s/bad", server.URL)))
+	require.NoError(t, err)
+	require.ErrorIs(t, confidant.Ping(ctx), majordomo.ErrUnavailable)
+}
+
+func TestClose(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("secret"))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	service, err := standard.New(ctx)
+	require.NoError(t, err)
+	confidant, err := httpconfidant.New(ctx)
+	require.NoError(t, err)
+	require.NoError(t, service.RegisterConfidant(ctx, confidant))
+
+	// Connections are reused across requests.
+	for i := 0; i < 2; i++ {
+		value, err := service.Fetch(ctx, server.URL)
+		require.NoError(t, err)
+		require.Equal(t, []byte("secret"), value)
+	}
+	// Pooled clients are also reused.
+	optsCtx := context.WithValue(ctx, &httpconfidant.MIMEType{}, "text/plain")
+	for i := 0; i < 2; i++ {
+		_, err := service.Fetch(optsCtx, server.URL)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, confidant.Close(ctx))
+	_, err = service.Fetch(ctx, server.URL)
+	require.ErrorIs(t, err, majordomo.ErrUnavailable)
+}
+
+func TestTracing(t *testing.T) {
+	traceParents := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceParents <- r.Header.Get("traceparent")
+		_, _ = w.Write([]byte("secret"))
+	}))
+	defer server.Close()
+
+	previous := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(previous)
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	ctx := context.Background()
+	service, err := standard.New(ctx, standard.WithTracerProvider(provider))
+	require.NoError(t, err)
+	confidant, err := httpconfidant.New(ctx, httpconfidant.WithTracerProvider(provider))
+	require.NoError(t, err)
+	require.NoError(t, service.RegisterConfidant(ctx, confidant))
+
+	_, err = service.Fetch(ctx, server.URL)
+	require.NoError(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	// The confidant span ends first, and is a child of the service span.
+	require.Equal(t, "confidant.Fetch", spans[0].Name())
+	require.Equal(t, "majordomo.Fetch", spans[1].Name())
+	require.Equal(t, spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+	// Both spans name the confidant in the same way.
+	for _, span := range spans {
+		require.Contains(t, span.Attributes(), attribute.String("majordomo.confidant", "http"))
+	}
+	// The trace context is propagated to the server.
+	require.Contains(t, <-traceParents, spans[0].SpanContext().TraceID().String())
+
+	// Without a tracer provider the trace context is not propagated.
+	confidant, err = httpconfidant.New(ctx)
+	require.NoError(t, err)
+	require.NoError(t, service.ReplaceConfidant(ctx, confidant))
+	_, err = service.Fetch(ctx, server.URL)
+	require.NoError(t, err)
+	require.Empty(t, <-traceParents)
+}
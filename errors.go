@@ -13,7 +13,11 @@
 
 package majordomo
 
-import "github.com/pkg/errors"
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
 
 // ErrNotFound is returned when a key cannot be found.
 var ErrNotFound = errors.New("key not known")
@@ -23,3 +27,72 @@ var ErrURLInvalid = errors.New("supplied URL is invalid")
 
 // ErrSchemeUnknown is returned when a confidant scheme is not found.
 var ErrSchemeUnknown = errors.New("no confidants registered to handle that scheme")
+
+// ErrNotSupported is returned when a confidant does not support the requested operation.
+var ErrNotSupported = errors.New("operation not supported by confidant")
+
+// ErrValueTooLarge is returned when a value exceeds the maximum permitted size.
+var ErrValueTooLarge = errors.New("value too large")
+
+// ErrPermissionDenied is returned when access to a value is denied.
+var ErrPermissionDenied = errors.New("permission denied")
+
+// ErrUnavailable is returned when a confidant is temporarily unable to provide a value.
+var ErrUnavailable = errors.New("confidant unavailable")
+
+// ErrTimeout is returned when a request to a confidant times out.
+var ErrTimeout = errors.New("request timed out")
+
+// ErrInvalidValue is returned when a value is obtained but cannot be decoded.
+var ErrInvalidValue = errors.New("value is invalid")
+
+// ErrNotReference is returned when a key is required to be a reference to a
+// value held by a confidant, but is a literal value.
+var ErrNotReference = errors.New("key is not a reference")
+
+// Error is an error that provides additional information about a failed request.
+// An Error matches its kind when compared with errors.Is(), for example
+// errors.Is(err, majordomo.ErrNotFound), and its cause can be obtained with
+// errors.Unwrap().
+type Error struct {
+	// Scheme is the scheme of the key for which the request failed.
+	Scheme string
+	// Key is the key for which the request failed, with any credentials removed.
+	Key string
+	// Kind is the well-known error that describes the failure, if any.
+	Kind error
+	// Err is the underlying cause of the failure, if any.
+	Err error
+}
+
+// NewError creates a new error of the given kind with the given cause.
+func NewError(kind error, err error) *Error {
+	return &Error{
+		Kind: kind,
+		Err:  err,
+	}
+}
+
+// Error returns the error message.
+func (e *Error) Error() string {
+	switch {
+	case e.Kind != nil && e.Err != nil:
+		return fmt.Sprintf("%s: %s", e.Kind.Error(), e.Err.Error())
+	case e.Kind != nil:
+		return e.Kind.Error()
+	case e.Err != nil:
+		return e.Err.Error()
+	default:
+		return "unknown error"
+	}
+}
+
+// Is returns true if the target is the kind of this error.
+func (e *Error) Is(target error) bool {
+	return e.Kind != nil && target == e.Kind
+}
+
+// Unwrap returns the underlying cause of the error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
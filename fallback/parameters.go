@@ -0,0 +1,77 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fallback
+
+import (
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	majordomo "github.com/wealdtech/go-majordomo"
+)
+
+type parameters struct {
+	logLevel            zerolog.Level
+	majordomo           majordomo.Service
+	fallBackUnavailable bool
+}
+
+// Parameter is the interface for service parameters.
+type Parameter interface {
+	apply(*parameters)
+}
+
+type parameterFunc func(*parameters)
+
+func (f parameterFunc) apply(p *parameters) {
+	f(p)
+}
+
+// WithLogLevel sets the log level for the module.
+func WithLogLevel(logLevel zerolog.Level) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.logLevel = logLevel
+	})
+}
+
+// WithMajordomo sets the majordomo service used to fetch references.
+func WithMajordomo(service majordomo.Service) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.majordomo = service
+	})
+}
+
+// WithFallBackUnavailable sets whether to fall back to the next reference when
+// a reference is unavailable or times out, as well as when it is not found.
+func WithFallBackUnavailable(fallBackUnavailable bool) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.fallBackUnavailable = fallBackUnavailable
+	})
+}
+
+// parseAndCheckParameters parses and checks parameters to ensure that mandatory parameters are present and correct.
+func parseAndCheckParameters(params ...Parameter) (*parameters, error) {
+	parameters := parameters{
+		logLevel: zerolog.GlobalLevel(),
+	}
+	for _, p := range params {
+		if params != nil {
+			p.apply(&parameters)
+		}
+	}
+
+	if parameters.majordomo == nil {
+		return nil, errors.New("no majordomo specified")
+	}
+
+	return &parameters, nil
+}
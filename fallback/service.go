@@ -0,0 +1,142 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fallback
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	zerologger "github.com/rs/zerolog/log"
+	majordomo "github.com/wealdtech/go-majordomo"
+	"github.com/wealdtech/go-majordomo/internal/redact"
+)
+
+// Separator separates the references in a fallback chain.
+const Separator = "|"
+
+// Service is a majordomo service that fetches the first available value from
+// a chain of references.
+// A chain is a key made up of references separated by "|", for example
+// "gsm://project/db-password|file:///secrets/db-password", and the references
+// are tried in order until one provides a value.  Only keys for which every
+// element is a reference are treated as chains; other keys, including literal
+// values that contain "|", are passed to the underlying service unaltered.
+type Service struct {
+	majordomo           majordomo.Service
+	fallBackUnavailable bool
+}
+
+// module-wide log.
+var log zerolog.Logger
+
+// New creates a new fallback majordomo service.
+func New(ctx context.Context, params ...Parameter) (*Service, error) {
+	parameters, err := parseAndCheckParameters(params...)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with parameters")
+	}
+
+	// Set logging.
+	log = zerologger.With().Str("service", "majordomo").Str("impl", "fallback").Logger()
+	if parameters.logLevel != log.GetLevel() {
+		log = log.Level(parameters.logLevel)
+	}
+
+	s := &Service{
+		majordomo:           parameters.majordomo,
+		fallBackUnavailable: parameters.fallBackUnavailable,
+	}
+
+	return s, nil
+}
+
+// Fetch fetches a value given its key.
+// If the key is a chain of references the value of the first reference that
+// provides one is returned.
+func (s *Service) Fetch(ctx context.Context, key string) ([]byte, error) {
+	keys := chain(key)
+	if keys == nil {
+		return s.majordomo.Fetch(ctx, key)
+	}
+
+	value, _, err := s.FetchFirst(ctx, keys...)
+	return value, err
+}
+
+// FetchFirst fetches the value of the first of the keys that provides one,
+// returning the index of the key that provided the value.
+// A key is skipped if it is not found, or if it is unavailable and falling
+// back on unavailability is enabled; any other error is returned immediately.
+// If no key provides a value the error for the last key is returned, unless an
+// earlier key was unavailable, in which case its error is returned as the value
+// could exist there.
+func (s *Service) FetchFirst(ctx context.Context, keys ...string) ([]byte, int, error) {
+	if len(keys) == 0 {
+		return nil, -1, majordomo.ErrNotFound
+	}
+
+	var unavailableErr error
+	var err error
+	for i, key := range keys {
+		var value []byte
+		value, err = s.majordomo.Fetch(ctx, key)
+		if err == nil {
+			if i == 0 {
+				log.Trace().Str("source", redact.Key(key)).Msg("Value obtained from primary reference")
+			} else {
+				log.Info().Str("source", redact.Key(key)).Int("position", i).Msg("Value obtained from fallback reference")
+			}
+			return value, i, nil
+		}
+
+		switch {
+		case errors.Is(err, majordomo.ErrNotFound):
+			log.Trace().Str("source", redact.Key(key)).Msg("Reference not found")
+		case s.fallBackUnavailable && (errors.Is(err, majordomo.ErrUnavailable) || errors.Is(err, majordomo.ErrTimeout)):
+			log.Debug().Str("source", redact.Key(key)).Err(err).Msg("Reference unavailable")
+			if unavailableErr == nil {
+				unavailableErr = err
+			}
+		default:
+			return nil, -1, err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	if unavailableErr != nil {
+		return nil, -1, unavailableErr
+	}
+	return nil, -1, err
+}
+
+// chain returns the references in a chain, or nil if the key is not a chain.
+func chain(key string) []string {
+	if !strings.Contains(key, Separator) {
+		return nil
+	}
+
+	keys := strings.Split(key, Separator)
+	for i := range keys {
+		keys[i] = strings.TrimSpace(keys[i])
+		if keyType, _ := majordomo.ClassifyKey(keys[i]); keyType != majordomo.KeyTypeReference {
+			return nil
+		}
+	}
+
+	return keys
+}
@@ -0,0 +1,178 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fallback_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	majordomo "github.com/wealdtech/go-majordomo"
+	"github.com/wealdtech/go-majordomo/fallback"
+)
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := fallback.New(ctx, fallback.WithLogLevel(zerolog.Disabled))
+	require.EqualError(t, err, "problem with parameters: no majordomo specified")
+
+	_, err = fallback.New(ctx, fallback.WithLogLevel(zerolog.Disabled), fallback.WithMajordomo(&MockMajordomo{}))
+	require.NoError(t, err)
+}
+
+func TestFetch(t *testing.T) {
+	tests := []struct {
+		name        string
+		key         string
+		unavailable bool
+		value       []byte
+		err         error
+		fetches     []string
+	}{
+		{
+			name:    "Single",
+			key:     "gsm:///found",
+			value:   []byte("gsm:///found"),
+			fetches: []string{"gsm:///found"},
+		},
+		{
+			name:    "Literal",
+			key:     "a|b",
+			value:   []byte("a|b"),
+			fetches: []string{"a|b"},
+		},
+		{
+			name:    "LiteralMixed",
+			key:     "gsm:///found|b",
+			value:   []byte("gsm:///found|b"),
+			fetches: []string{"gsm:///found|b"},
+		},
+		{
+			name:    "Primary",
+			key:     "gsm:///found|file:///found",
+			value:   []byte("gsm:///found"),
+			fetches: []string{"gsm:///found"},
+		},
+		{
+			name:    "Fallback",
+			key:     "gsm:///missing | file:///found",
+			value:   []byte("file:///found"),
+			fetches: []string{"gsm:///missing", "file:///found"},
+		},
+		{
+			name:    "AllMissing",
+			key:     "gsm:///missing|file:///missing",
+			err:     majordomo.ErrNotFound,
+			fetches: []string{"gsm:///missing", "file:///missing"},
+		},
+		{
+			name:    "Unavailable",
+			key:     "gsm:///unavailable|file:///found",
+			err:     majordomo.ErrUnavailable,
+			fetches: []string{"gsm:///unavailable"},
+		},
+		{
+			name:        "UnavailableFallBack",
+			key:         "gsm:///unavailable|file:///found",
+			unavailable: true,
+			value:       []byte("file:///found"),
+			fetches:     []string{"gsm:///unavailable", "file:///found"},
+		},
+		{
+			name:        "UnavailableThenMissing",
+			key:         "gsm:///unavailable|file:///missing",
+			unavailable: true,
+			err:         majordomo.ErrUnavailable,
+			fetches:     []string{"gsm:///unavailable", "file:///missing"},
+		},
+		{
+			name:        "Denied",
+			key:         "gsm:///denied|file:///found",
+			unavailable: true,
+			err:         majordomo.ErrPermissionDenied,
+			fetches:     []string{"gsm:///denied"},
+		},
+	}
+
+	ctx := context.Background()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mock := &MockMajordomo{}
+			service, err := fallback.New(ctx,
+				fallback.WithLogLevel(zerolog.Disabled),
+				fallback.WithMajordomo(mock),
+				fallback.WithFallBackUnavailable(test.unavailable),
+			)
+			require.NoError(t, err)
+			value, err := service.Fetch(ctx, test.key)
+			if test.err != nil {
+				require.True(t, errors.Is(err, test.err), err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, test.value, value)
+			}
+			require.Equal(t, test.fetches, mock.fetches)
+		})
+	}
+}
+
+func TestFetchFirst(t *testing.T) {
+	ctx := context.Background()
+	service, err := fallback.New(ctx, fallback.WithLogLevel(zerolog.Disabled), fallback.WithMajordomo(&MockMajordomo{}))
+	require.NoError(t, err)
+
+	_, index, err := service.FetchFirst(ctx)
+	require.True(t, errors.Is(err, majordomo.ErrNotFound))
+	require.Equal(t, -1, index)
+
+	value, index, err := service.FetchFirst(ctx, "gsm:///missing", "asm:///missing", "file:///found")
+	require.NoError(t, err)
+	require.Equal(t, []byte("file:///found"), value)
+	require.Equal(t, 2, index)
+
+	// Keys supplied directly do not have to be references.
+	value, index, err = service.FetchFirst(ctx, "gsm:///missing", "default")
+	require.NoError(t, err)
+	require.Equal(t, []byte("default"), value)
+	require.Equal(t, 1, index)
+}
+
+// MockMajordomo is a mock majordomo service that records the keys fetched.
+// Keys are returned as their values, unless their path requests an error.
+type MockMajordomo struct {
+	mu      sync.Mutex
+	fetches []string
+}
+
+func (m *MockMajordomo) Fetch(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	m.fetches = append(m.fetches, key)
+	m.mu.Unlock()
+
+	switch {
+	case strings.HasSuffix(key, ":///missing"):
+		return nil, majordomo.ErrNotFound
+	case strings.HasSuffix(key, ":///unavailable"):
+		return nil, majordomo.ErrUnavailable
+	case strings.HasSuffix(key, ":///denied"):
+		return nil, majordomo.ErrPermissionDenied
+	default:
+		return []byte(key), nil
+	}
+}
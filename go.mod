@@ -5,13 +5,21 @@ go 1.14
 require (
 	cloud.google.com/go v0.103.0 // indirect
 	cloud.google.com/go/secretmanager v1.5.0
-	github.com/aws/aws-sdk-go v1.44.81
+	github.com/BurntSushi/toml v1.2.1
+	github.com/aws/aws-sdk-go v1.50.0
+	github.com/fsnotify/fsnotify v1.6.0
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/pkg/errors v0.9.1
+	github.com/prometheus/client_golang v1.12.2
 	github.com/rs/zerolog v1.27.0
 	github.com/stretchr/testify v1.8.0
-	golang.org/x/sys v0.0.0-20220818161305-2296e01440c6 // indirect
+	go.opentelemetry.io/otel v1.0.1
+	go.opentelemetry.io/otel/sdk v1.0.1
+	go.opentelemetry.io/otel/trace v1.0.1
+	golang.org/x/oauth2 v0.0.0-20220622183110-fd043fe589d2
 	google.golang.org/api v0.93.0
 	google.golang.org/genproto v0.0.0-20220819174105-e9f053255caa
+	google.golang.org/grpc v1.48.0
+	gopkg.in/yaml.v3 v3.0.1
 	gotest.tools v2.2.0+incompatible
 )
@@ -0,0 +1,33 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package memory provides functions for handling byte slices that hold
+// sensitive values.
+package memory
+
+// Zero zeroes a byte slice.
+func Zero(data []byte) {
+	for i := range data {
+		data[i] = 0
+	}
+}
+
+// Copy returns a copy of a byte slice.  A nil slice returns nil.
+func Copy(data []byte) []byte {
+	if data == nil {
+		return nil
+	}
+	res := make([]byte, len(data))
+	copy(res, data)
+	return res
+}
@@ -0,0 +1,83 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package poll provides a polling implementation of watching values, for
+// confidants that have no native way of being notified of changes.
+package poll
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	majordomo "github.com/wealdtech/go-majordomo"
+)
+
+// FetchFunc fetches the current value.
+type FetchFunc func(ctx context.Context) ([]byte, error)
+
+// Watch calls fetch immediately and then every interval, sending an event
+// whenever the value or error differs from that previously sent.
+// The returned channel is closed when the context is cancelled.
+func Watch(ctx context.Context, interval time.Duration, fetch FetchFunc) <-chan *majordomo.WatchEvent {
+	ch := make(chan *majordomo.WatchEvent, 1)
+
+	go func() {
+		defer close(ch)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		var last *majordomo.WatchEvent
+		for {
+			value, err := fetch(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			event := &majordomo.WatchEvent{
+				Value: value,
+				Err:   err,
+			}
+			if Changed(last, event) {
+				select {
+				case ch <- event:
+					last = event
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch
+}
+
+// Changed returns true if the event differs from the previous event.
+func Changed(previous *majordomo.WatchEvent, event *majordomo.WatchEvent) bool {
+	if previous == nil {
+		return true
+	}
+	if (previous.Err == nil) != (event.Err == nil) {
+		return true
+	}
+	if event.Err != nil {
+		return previous.Err.Error() != event.Err.Error()
+	}
+
+	return !bytes.Equal(previous.Value, event.Value)
+}
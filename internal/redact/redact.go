@@ -0,0 +1,57 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package redact provides redaction of keys so that they can be logged,
+// returned in errors and recorded in audit events.
+package redact
+
+import (
+	"net/url"
+	"strings"
+)
+
+// directScheme is the scheme of the direct confidant, whose keys hold their
+// values.
+const directScheme = "direct"
+
+// URL returns the key for a URL with any credentials, query parameters and
+// fragment removed.  Keys that hold their values, such as those for the
+// direct confidant, have their host and path replaced.
+func URL(url *url.URL) string {
+	if HoldsValue(url) {
+		return directScheme + ":///<redacted>"
+	}
+	redacted := *url
+	redacted.User = nil
+	redacted.RawQuery = ""
+	redacted.ForceQuery = false
+	redacted.Fragment = ""
+	return redacted.String()
+}
+
+// Key returns a key with any credentials, query parameters and fragment
+// removed.  Keys that cannot be parsed could contain anything, so are
+// returned as "<invalid>".
+func Key(key string) string {
+	url, err := url.Parse(key)
+	if err != nil {
+		return "<invalid>"
+	}
+	return URL(url)
+}
+
+// HoldsValue returns true if the URL holds its value, as do those for the
+// direct confidant.
+func HoldsValue(url *url.URL) bool {
+	return strings.EqualFold(url.Scheme, directScheme)
+}
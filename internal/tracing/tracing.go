@@ -0,0 +1,79 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tracing provides OpenTelemetry spans for majordomo operations.
+// Spans never carry keys or values, as they can contain sensitive information.
+package tracing
+
+import (
+	"context"
+
+	"github.com/wealdtech/go-majordomo/metrics"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName is the name of the instrumentation library.
+const instrumentationName = "github.com/wealdtech/go-majordomo"
+
+// Attribute keys.
+const (
+	// SchemeKey is the scheme of the key.
+	SchemeKey = attribute.Key("majordomo.scheme")
+	// ConfidantKey is the name of the confidant, which is the name of the
+	// package that implements it, for example "asm".
+	ConfidantKey = attribute.Key("majordomo.confidant")
+	// OutcomeKey is the outcome of the operation: "found", "not_found" or "error".
+	OutcomeKey = attribute.Key("majordomo.outcome")
+	// ErrorClassKey is the class of the error, if the outcome is "error".
+	ErrorClassKey = attribute.Key("majordomo.error_class")
+)
+
+// Tracer returns a tracer from the provider.
+// If the provider is nil the tracer is a no-op.
+func Tracer(provider trace.TracerProvider) trace.Tracer {
+	if provider == nil {
+		provider = trace.NewNoopTracerProvider()
+	}
+	return provider.Tracer(instrumentationName)
+}
+
+// Start starts a span for an operation on a key with the given scheme,
+// carried out by the given confidant implementation.
+func Start(ctx context.Context, tracer trace.Tracer, name string, scheme string, confidant string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	opts = append(opts, trace.WithAttributes(
+		SchemeKey.String(scheme),
+		ConfidantKey.String(confidant),
+	))
+	return tracer.Start(ctx, name, opts...)
+}
+
+// End records the outcome of the operation and ends the span.
+// Only the class of any error is recorded, as error messages can contain
+// details of the key.
+func End(span trace.Span, err error) {
+	switch outcome := metrics.OutcomeOf(err); outcome {
+	case metrics.OutcomeFound:
+		span.SetAttributes(OutcomeKey.String("found"))
+	case metrics.OutcomeNotFound:
+		span.SetAttributes(OutcomeKey.String("not_found"))
+	default:
+		span.SetAttributes(
+			OutcomeKey.String("error"),
+			ErrorClassKey.String(string(outcome)),
+		)
+		span.SetStatus(codes.Error, string(outcome))
+	}
+	span.End()
+}
@@ -0,0 +1,84 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package majordomo
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// KeyType is the type of a key.
+type KeyType int
+
+const (
+	// KeyTypeMalformed is a key that is neither a valid literal nor a valid reference.
+	KeyTypeMalformed KeyType = iota
+	// KeyTypeLiteral is a key that is itself the value.
+	KeyTypeLiteral
+	// KeyTypeReference is a key that is a URL referencing a value held by a confidant.
+	KeyTypeReference
+)
+
+var keyTypeStrings = [...]string{
+	"malformed",
+	"literal",
+	"reference",
+}
+
+// String returns a string representation of the key type.
+func (t KeyType) String() string {
+	if t < 0 || int(t) >= len(keyTypeStrings) {
+		return "unknown"
+	}
+	return keyTypeStrings[t]
+}
+
+// nearReferenceRegex matches keys that start with a URL scheme followed by a
+// colon and a slash, which are likely to be mistyped references such as
+// "file:/etc/secret".
+var nearReferenceRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:/`)
+
+// ClassifyKey classifies a key as a literal value, a reference to a value held
+// by a confidant, or malformed.  If the key is malformed the returned error
+// describes the problem.
+// Keys that contain "://" are references.  Keys that look like mistyped
+// references, such as "file:/etc/secret", are reported as malformed even
+// though a service that accepts literals would return them as values.
+// ClassifyKey does not check that a confidant is registered for the scheme.
+func ClassifyKey(key string) (KeyType, error) {
+	if key == "" {
+		return KeyTypeMalformed, errors.New("key is empty")
+	}
+
+	if !strings.Contains(key, "://") {
+		if nearReferenceRegex.MatchString(key) {
+			return KeyTypeMalformed, fmt.Errorf("key starts with %q but is not a reference; is \"//\" missing?", key[:strings.Index(key, ":")+1])
+		}
+		return KeyTypeLiteral, nil
+	}
+
+	url, err := url.Parse(key)
+	if err != nil {
+		return KeyTypeMalformed, errors.Wrap(err, "key is not a valid URL")
+	}
+	if url.Scheme == "" {
+		return KeyTypeMalformed, errors.New("key has no scheme")
+	}
+
+	return KeyTypeReference, nil
+}
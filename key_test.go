@@ -0,0 +1,94 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package majordomo_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	majordomo "github.com/wealdtech/go-majordomo"
+)
+
+func TestClassifyKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		keyType majordomo.KeyType
+		err     string
+	}{
+		{
+			name:    "Empty",
+			key:     "",
+			keyType: majordomo.KeyTypeMalformed,
+			err:     "key is empty",
+		},
+		{
+			name:    "Literal",
+			key:     "secret value",
+			keyType: majordomo.KeyTypeLiteral,
+		},
+		{
+			name:    "LiteralWithColon",
+			key:     "user:password",
+			keyType: majordomo.KeyTypeLiteral,
+		},
+		{
+			name:    "Reference",
+			key:     "file:///etc/secret",
+			keyType: majordomo.KeyTypeReference,
+		},
+		{
+			name:    "ReferenceAlias",
+			key:     "gsm-prod://project/secret",
+			keyType: majordomo.KeyTypeReference,
+		},
+		{
+			name:    "MissingSlash",
+			key:     "file:/etc/secret",
+			keyType: majordomo.KeyTypeMalformed,
+			err:     `key starts with "file:" but is not a reference; is "//" missing?`,
+		},
+		{
+			name:    "NoScheme",
+			key:     "://secret",
+			keyType: majordomo.KeyTypeMalformed,
+			err:     "key is not a valid URL: parse \"://secret\": missing protocol scheme",
+		},
+		{
+			name:    "InvalidScheme",
+			key:     "bad scheme://secret",
+			keyType: majordomo.KeyTypeMalformed,
+			err:     "key is not a valid URL: parse \"bad scheme://secret\": first path segment in URL cannot contain colon",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keyType, err := majordomo.ClassifyKey(test.key)
+			require.Equal(t, test.keyType, keyType)
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestKeyTypeString(t *testing.T) {
+	require.Equal(t, "malformed", majordomo.KeyTypeMalformed.String())
+	require.Equal(t, "literal", majordomo.KeyTypeLiteral.String())
+	require.Equal(t, "reference", majordomo.KeyTypeReference.String())
+	require.Equal(t, "unknown", majordomo.KeyType(99).String())
+}
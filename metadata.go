@@ -0,0 +1,37 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package majordomo
+
+import "time"
+
+// Metadata contains information about a secret value.
+// Confidants populate whichever fields are available to them; fields that are
+// not available are left as their zero value.
+type Metadata struct {
+	// ID is the confidant-specific identifier of the secret, for example
+	// an ARN, a resource name or a file path.
+	ID string
+	// Version is the identifier of the version of the secret that was returned.
+	Version string
+	// VersionStages are the labels attached to the version of the secret that was returned.
+	VersionStages []string
+	// Created is the time at which the secret, or version of the secret, was created.
+	Created time.Time
+	// Modified is the time at which the secret was last modified.
+	Modified time.Time
+	// Expires is the time at which the secret expires.
+	Expires time.Time
+	// Attributes are additional confidant-specific items of information.
+	Attributes map[string]string
+}
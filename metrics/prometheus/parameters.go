@@ -0,0 +1,81 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+)
+
+type parameters struct {
+	logLevel   zerolog.Level
+	namespace  string
+	registerer prometheus.Registerer
+}
+
+// Parameter is the interface for service parameters.
+type Parameter interface {
+	apply(*parameters)
+}
+
+type parameterFunc func(*parameters)
+
+func (f parameterFunc) apply(p *parameters) {
+	f(p)
+}
+
+// WithLogLevel sets the log level for the module.
+func WithLogLevel(logLevel zerolog.Level) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.logLevel = logLevel
+	})
+}
+
+// WithNamespace sets the namespace of the metrics.
+func WithNamespace(namespace string) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.namespace = namespace
+	})
+}
+
+// WithRegisterer sets the registerer with which the metrics are registered.
+func WithRegisterer(registerer prometheus.Registerer) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.registerer = registerer
+	})
+}
+
+// parseAndCheckParameters parses and checks parameters to ensure that mandatory parameters are present and correct.
+func parseAndCheckParameters(params ...Parameter) (*parameters, error) {
+	parameters := parameters{
+		logLevel:   zerolog.GlobalLevel(),
+		namespace:  "majordomo",
+		registerer: prometheus.DefaultRegisterer,
+	}
+	for _, p := range params {
+		if params != nil {
+			p.apply(&parameters)
+		}
+	}
+
+	if parameters.namespace == "" {
+		return nil, errors.New("no namespace specified")
+	}
+	if parameters.registerer == nil {
+		return nil, errors.New("no registerer specified")
+	}
+
+	return &parameters, nil
+}
@@ -0,0 +1,101 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	zerologger "github.com/rs/zerolog/log"
+	"github.com/wealdtech/go-majordomo/metrics"
+)
+
+// Service is a metrics service that exposes metrics to Prometheus.
+// The following metrics are provided, with the configured namespace:
+//   - fetches_total: the number of fetches, by scheme and outcome
+//   - fetch_duration_seconds: the duration of fetches, by scheme
+//   - confidants_registered: the number of confidants registered, by scheme
+type Service struct {
+	fetches       *prometheus.CounterVec
+	fetchDuration *prometheus.HistogramVec
+	confidants    *prometheus.GaugeVec
+	// confidantsMu ensures that the confidant gauges are replaced atomically.
+	confidantsMu sync.Mutex
+}
+
+// module-wide log.
+var log zerolog.Logger
+
+// New creates a new Prometheus metrics service.
+func New(ctx context.Context, params ...Parameter) (*Service, error) {
+	parameters, err := parseAndCheckParameters(params...)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with parameters")
+	}
+
+	// Set logging.
+	log = zerologger.With().Str("service", "metrics").Str("impl", "prometheus").Logger()
+	if parameters.logLevel != log.GetLevel() {
+		log = log.Level(parameters.logLevel)
+	}
+
+	s := &Service{
+		fetches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: parameters.namespace,
+			Name:      "fetches_total",
+			Help:      "The number of fetches from confidants.",
+		}, []string{"scheme", "outcome"}),
+		fetchDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: parameters.namespace,
+			Name:      "fetch_duration_seconds",
+			Help:      "The time taken to fetch from confidants.",
+			Buckets:   []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+		}, []string{"scheme"}),
+		confidants: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: parameters.namespace,
+			Name:      "confidants_registered",
+			Help:      "The number of confidants registered.",
+		}, []string{"scheme"}),
+	}
+
+	for _, collector := range []prometheus.Collector{s.fetches, s.fetchDuration, s.confidants} {
+		if err := parameters.registerer.Register(collector); err != nil {
+			return nil, errors.Wrap(err, "failed to register metric")
+		}
+	}
+	log.Trace().Msg("Registered metrics")
+
+	return s, nil
+}
+
+// FetchCompleted records the completion of a fetch from a confidant.
+func (s *Service) FetchCompleted(scheme string, outcome metrics.Outcome, duration time.Duration) {
+	s.fetches.WithLabelValues(scheme, string(outcome)).Inc()
+	s.fetchDuration.WithLabelValues(scheme).Observe(duration.Seconds())
+}
+
+// ConfidantsRegistered records the number of confidants registered for each scheme.
+func (s *Service) ConfidantsRegistered(counts map[string]int) {
+	s.confidantsMu.Lock()
+	defer s.confidantsMu.Unlock()
+
+	s.confidants.Reset()
+	for scheme, count := range counts {
+		s.confidants.WithLabelValues(scheme).Set(float64(count))
+	}
+}
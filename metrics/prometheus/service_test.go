@@ -0,0 +1,81 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"github.com/wealdtech/go-majordomo/metrics"
+	"github.com/wealdtech/go-majordomo/metrics/prometheus"
+)
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := prometheus.New(ctx, prometheus.WithLogLevel(zerolog.Disabled), prometheus.WithNamespace(""))
+	require.EqualError(t, err, "problem with parameters: no namespace specified")
+
+	_, err = prometheus.New(ctx, prometheus.WithLogLevel(zerolog.Disabled), prometheus.WithRegisterer(nil))
+	require.EqualError(t, err, "problem with parameters: no registerer specified")
+
+	registry := prom.NewRegistry()
+	_, err = prometheus.New(ctx, prometheus.WithLogLevel(zerolog.Disabled), prometheus.WithRegisterer(registry))
+	require.NoError(t, err)
+
+	// Registering twice with the same registerer fails.
+	_, err = prometheus.New(ctx, prometheus.WithLogLevel(zerolog.Disabled), prometheus.WithRegisterer(registry))
+	require.Error(t, err)
+}
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+	registry := prom.NewRegistry()
+	service, err := prometheus.New(ctx,
+		prometheus.WithLogLevel(zerolog.Disabled),
+		prometheus.WithRegisterer(registry),
+		prometheus.WithNamespace("test"),
+	)
+	require.NoError(t, err)
+
+	service.FetchCompleted("gsm", metrics.OutcomeFound, 20*time.Millisecond)
+	service.FetchCompleted("gsm", metrics.OutcomeFound, 30*time.Millisecond)
+	service.FetchCompleted("gsm", metrics.OutcomeNotFound, 10*time.Millisecond)
+	service.FetchCompleted("asm", metrics.OutcomeUnavailable, time.Second)
+
+	require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP test_fetches_total The number of fetches from confidants.
+# TYPE test_fetches_total counter
+test_fetches_total{outcome="found",scheme="gsm"} 2
+test_fetches_total{outcome="not_found",scheme="gsm"} 1
+test_fetches_total{outcome="unavailable",scheme="asm"} 1
+`), "test_fetches_total"))
+	count, err := testutil.GatherAndCount(registry, "test_fetch_duration_seconds")
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	service.ConfidantsRegistered(map[string]int{"gsm": 1, "asm": 2})
+	service.ConfidantsRegistered(map[string]int{"gsm": 1})
+	require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP test_confidants_registered The number of confidants registered.
+# TYPE test_confidants_registered gauge
+test_confidants_registered{scheme="gsm"} 1
+`), "test_confidants_registered"))
+}
@@ -0,0 +1,79 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics defines the interface for recording majordomo metrics.
+// Metrics are labelled by scheme and never by key, as keys can be unbounded
+// in number and can contain sensitive information.
+package metrics
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	majordomo "github.com/wealdtech/go-majordomo"
+)
+
+// Service is the interface for a metrics service.
+type Service interface {
+	// FetchCompleted records the completion of a fetch from a confidant.
+	FetchCompleted(scheme string, outcome Outcome, duration time.Duration)
+	// ConfidantsRegistered records the number of confidants registered for
+	// each scheme.  The supplied counts replace any previously recorded.
+	ConfidantsRegistered(counts map[string]int)
+}
+
+// Outcome is the outcome of a fetch.
+type Outcome string
+
+const (
+	// OutcomeFound is a fetch that returned a value.
+	OutcomeFound Outcome = "found"
+	// OutcomeNotFound is a fetch for which the value was not found.
+	OutcomeNotFound Outcome = "not_found"
+	// OutcomePermissionDenied is a fetch for which access was denied.
+	OutcomePermissionDenied Outcome = "permission_denied"
+	// OutcomeUnavailable is a fetch for which the confidant was unavailable.
+	OutcomeUnavailable Outcome = "unavailable"
+	// OutcomeTimeout is a fetch that timed out.
+	OutcomeTimeout Outcome = "timeout"
+	// OutcomeInvalid is a fetch for which the key or value was invalid.
+	OutcomeInvalid Outcome = "invalid"
+	// OutcomeNotSupported is a fetch for an operation the confidant does not support.
+	OutcomeNotSupported Outcome = "not_supported"
+	// OutcomeError is a fetch that failed for any other reason.
+	OutcomeError Outcome = "error"
+)
+
+// OutcomeOf returns the outcome of a fetch given its error.
+func OutcomeOf(err error) Outcome {
+	switch {
+	case err == nil:
+		return OutcomeFound
+	case errors.Is(err, majordomo.ErrNotFound):
+		return OutcomeNotFound
+	case errors.Is(err, majordomo.ErrPermissionDenied):
+		return OutcomePermissionDenied
+	case errors.Is(err, majordomo.ErrUnavailable):
+		return OutcomeUnavailable
+	case errors.Is(err, majordomo.ErrTimeout):
+		return OutcomeTimeout
+	case errors.Is(err, majordomo.ErrURLInvalid),
+		errors.Is(err, majordomo.ErrInvalidValue),
+		errors.Is(err, majordomo.ErrValueTooLarge):
+		return OutcomeInvalid
+	case errors.Is(err, majordomo.ErrNotSupported):
+		return OutcomeNotSupported
+	default:
+		return OutcomeError
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	majordomo "github.com/wealdtech/go-majordomo"
+	"github.com/wealdtech/go-majordomo/metrics"
+)
+
+func TestOutcomeOf(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		outcome metrics.Outcome
+	}{
+		{
+			name:    "Nil",
+			outcome: metrics.OutcomeFound,
+		},
+		{
+			name:    "NotFound",
+			err:     majordomo.ErrNotFound,
+			outcome: metrics.OutcomeNotFound,
+		},
+		{
+			name:    "NotFoundWrapped",
+			err:     majordomo.NewError(majordomo.ErrNotFound, errors.New("no such secret")),
+			outcome: metrics.OutcomeNotFound,
+		},
+		{
+			name:    "PermissionDenied",
+			err:     majordomo.ErrPermissionDenied,
+			outcome: metrics.OutcomePermissionDenied,
+		},
+		{
+			name:    "Unavailable",
+			err:     majordomo.ErrUnavailable,
+			outcome: metrics.OutcomeUnavailable,
+		},
+		{
+			name:    "Timeout",
+			err:     majordomo.ErrTimeout,
+			outcome: metrics.OutcomeTimeout,
+		},
+		{
+			name:    "InvalidValue",
+			err:     majordomo.ErrInvalidValue,
+			outcome: metrics.OutcomeInvalid,
+		},
+		{
+			name:    "NotSupported",
+			err:     majordomo.ErrNotSupported,
+			outcome: metrics.OutcomeNotSupported,
+		},
+		{
+			name:    "Other",
+			err:     errors.New("other"),
+			outcome: metrics.OutcomeError,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.outcome, metrics.OutcomeOf(test.err))
+		})
+	}
+}
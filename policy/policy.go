@@ -0,0 +1,232 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package policy provides access policies that restrict the keys a caller
+// may access.
+package policy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrDenied is the cause of the error returned when a policy denies access
+// to a key.  The error returned is of kind majordomo.ErrPermissionDenied.
+var ErrDenied = errors.New("access denied by policy")
+
+// Identity is a context tag for the identity of the caller, as a string.
+// For example:
+//
+//	ctx = context.WithValue(ctx, &policy.Identity{}, "tenant-a")
+type Identity struct{}
+
+// ContextIdentity returns the identity of the caller supplied in the context,
+// or an empty string if none was supplied.
+func ContextIdentity(ctx context.Context) string {
+	identity, _ := ctx.Value(&Identity{}).(string)
+	return identity
+}
+
+// Effect is the effect of a rule.
+type Effect string
+
+const (
+	// EffectAllow allows access to matching keys.
+	EffectAllow Effect = "allow"
+	// EffectDeny denies access to matching keys.
+	EffectDeny Effect = "deny"
+)
+
+// Rule is a rule in a policy.
+// Identities are matched against the identity of the caller, and scheme,
+// host and path against the key.  Each is a glob pattern as per path.Match();
+// path may also contain "**" to match any number of path segments, for
+// example "tenant-a/**".  Empty fields match anything, as does an empty list
+// of identities.
+type Rule struct {
+	Effect     Effect   `json:"effect" yaml:"effect"`
+	Identities []string `json:"identities,omitempty" yaml:"identities,omitempty"`
+	Scheme     string   `json:"scheme,omitempty" yaml:"scheme,omitempty"`
+	Host       string   `json:"host,omitempty" yaml:"host,omitempty"`
+	Path       string   `json:"path,omitempty" yaml:"path,omitempty"`
+}
+
+// Policy is a set of rules that decide if a caller may access a key.
+// Deny rules take precedence over allow rules, and access to keys that do
+// not match any allow rule is denied.
+type Policy struct {
+	rules []*Rule
+}
+
+// file is the format of a policy file.
+type file struct {
+	Rules []*Rule `yaml:"rules"`
+}
+
+// New creates a new policy from a set of rules.
+func New(rules []*Rule) (*Policy, error) {
+	for i, rule := range rules {
+		if err := rule.check(); err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("invalid rule %d", i))
+		}
+	}
+
+	return &Policy{
+		rules: rules,
+	}, nil
+}
+
+// LoadFile loads a policy from a YAML or JSON file.  The file contains a list
+// of rules, for example:
+//
+//	rules:
+//	  - effect: allow
+//	    identities: ["tenant-a"]
+//	    scheme: asm
+//	    path: "tenant-a/**"
+func LoadFile(name string) (*Policy, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read policy file")
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	// Reject unknown fields, as a mistyped field would otherwise widen the rule.
+	decoder.KnownFields(true)
+	var contents file
+	if err := decoder.Decode(&contents); err != nil {
+		return nil, errors.Wrap(err, "failed to parse policy file")
+	}
+
+	return New(contents.Rules)
+}
+
+// Allowed returns true if the policy allows the identity to access the key.
+// Keys with "." or ".." path segments, or with empty path segments other than
+// a trailing slash, are never allowed, as the value they refer to may not be
+// that given by their path.
+// Schemes and hosts are compared case-insensitively.  Keys without a host are
+// denied by any deny rule that sets a host, as the confidant may supply that
+// host as its default.
+func (p *Policy) Allowed(identity string, key *url.URL) bool {
+	keyPath := strings.TrimPrefix(key.Path, "/")
+	if keyPath != "" {
+		segments := strings.Split(keyPath, "/")
+		for i, segment := range segments {
+			if segment == "." || segment == ".." || (segment == "" && i != len(segments)-1) {
+				return false
+			}
+		}
+	}
+
+	allowed := false
+	for _, rule := range p.rules {
+		if !rule.matches(identity, key.Scheme, key.Host, keyPath) {
+			continue
+		}
+		if rule.Effect == EffectDeny {
+			return false
+		}
+		allowed = true
+	}
+
+	return allowed
+}
+
+// check checks that a rule is valid.
+func (r *Rule) check() error {
+	if r == nil {
+		return errors.New("rule is empty")
+	}
+	if r.Effect != EffectAllow && r.Effect != EffectDeny {
+		return fmt.Errorf("effect %q unknown", r.Effect)
+	}
+	patterns := append([]string{r.Scheme, r.Host}, r.Identities...)
+	patterns = append(patterns, strings.Split(strings.TrimPrefix(r.Path, "/"), "/")...)
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("pattern %q invalid", pattern)
+		}
+	}
+
+	return nil
+}
+
+// matches returns true if the rule matches the identity and key.
+func (r *Rule) matches(identity string, scheme string, host string, keyPath string) bool {
+	if len(r.Identities) > 0 {
+		matched := false
+		for _, pattern := range r.Identities {
+			if match(pattern, identity) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if r.Scheme != "" && !match(strings.ToLower(r.Scheme), strings.ToLower(scheme)) {
+		return false
+	}
+	if r.Host != "" && !match(strings.ToLower(r.Host), strings.ToLower(host)) {
+		// The host a key without one resolves to is not known, so fail closed.
+		if host != "" || r.Effect != EffectDeny {
+			return false
+		}
+	}
+	if r.Path != "" && !matchSegments(strings.Split(strings.TrimPrefix(r.Path, "/"), "/"), strings.Split(keyPath, "/")) {
+		return false
+	}
+
+	return true
+}
+
+// match returns true if the name matches the pattern.
+func match(pattern string, name string) bool {
+	matched, err := path.Match(pattern, name)
+	return err == nil && matched
+}
+
+// matchSegments returns true if the path segments match the pattern segments.
+// A pattern segment of "**" matches any number of path segments.
+func matchSegments(patterns []string, segments []string) bool {
+	for len(patterns) > 0 {
+		if patterns[0] == "**" {
+			if len(patterns) == 1 {
+				return true
+			}
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(patterns[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 || !match(patterns[0], segments[0]) {
+			return false
+		}
+		patterns = patterns[1:]
+		segments = segments[1:]
+	}
+
+	return len(segments) == 0
+}
@@ -0,0 +1,319 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wealdtech/go-majordomo/policy"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []*policy.Rule
+		err   string
+	}{
+		{
+			name: "Empty",
+		},
+		{
+			name:  "Nil",
+			rules: []*policy.Rule{nil},
+			err:   "invalid rule 0: rule is empty",
+		},
+		{
+			name:  "EffectMissing",
+			rules: []*policy.Rule{{Scheme: "asm"}},
+			err:   `invalid rule 0: effect "" unknown`,
+		},
+		{
+			name: "PatternInvalid",
+			rules: []*policy.Rule{
+				{Effect: policy.EffectAllow},
+				{Effect: policy.EffectDeny, Path: "tenant-a/[x"},
+			},
+			err: `invalid rule 1: pattern "[x" invalid`,
+		},
+		{
+			name: "Good",
+			rules: []*policy.Rule{
+				{Effect: policy.EffectAllow, Identities: []string{"tenant-*"}, Scheme: "asm", Host: "*", Path: "/tenant-a/**"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := policy.New(test.rules)
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	accessPolicy, err := policy.New([]*policy.Rule{
+		{Effect: policy.EffectAllow, Identities: []string{"tenant-a"}, Scheme: "asm", Path: "tenant-a/**"},
+		{Effect: policy.EffectAllow, Identities: []string{"tenant-b"}, Scheme: "asm", Host: "eu-*", Path: "tenant-b/*/key"},
+		{Effect: policy.EffectAllow, Scheme: "file", Path: "shared/*"},
+		{Effect: policy.EffectDeny, Path: "**/private"},
+		{Effect: policy.EffectAllow, Identities: []string{"admin-*"}},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		identity string
+		key      string
+		allowed  bool
+	}{
+		{
+			name:     "Allowed",
+			identity: "tenant-a",
+			key:      "asm://eu-west-1/tenant-a/db",
+			allowed:  true,
+		},
+		{
+			name:     "AllowedNested",
+			identity: "tenant-a",
+			key:      "asm://eu-west-1/tenant-a/db/password",
+			allowed:  true,
+		},
+		{
+			name:     "OtherTenant",
+			identity: "tenant-a",
+			key:      "asm://eu-west-1/tenant-b/x/key",
+		},
+		{
+			name:     "OtherScheme",
+			identity: "tenant-a",
+			key:      "gsm://project/tenant-a/db",
+		},
+		{
+			name:     "HostPattern",
+			identity: "tenant-b",
+			key:      "asm://eu-west-1/tenant-b/x/key",
+			allowed:  true,
+		},
+		{
+			name:     "HostMismatch",
+			identity: "tenant-b",
+			key:      "asm://us-east-1/tenant-b/x/key",
+		},
+		{
+			name:     "SegmentMismatch",
+			identity: "tenant-b",
+			key:      "asm://eu-west-1/tenant-b/x/y/key",
+		},
+		{
+			name:    "AnyIdentity",
+			key:     "file:///shared/config",
+			allowed: true,
+		},
+		{
+			name:     "SingleSegment",
+			identity: "tenant-a",
+			key:      "file:///shared/nested/config",
+		},
+		{
+			name:     "Denied",
+			identity: "tenant-a",
+			key:      "asm://eu-west-1/tenant-a/db/private",
+		},
+		{
+			name:     "IdentityPattern",
+			identity: "admin-1",
+			key:      "gsm://project/anything",
+			allowed:  true,
+		},
+		{
+			name:     "DeniedOverridesAllowed",
+			identity: "admin-1",
+			key:      "gsm://project/private",
+		},
+		{
+			name:     "DotSegment",
+			identity: "tenant-a",
+			key:      "asm://eu-west-1/tenant-a/../tenant-b/x/key",
+		},
+		{
+			name:     "EmptySegment",
+			identity: "tenant-a",
+			key:      "asm://eu-west-1/tenant-a//db",
+		},
+		{
+			name:     "TrailingSlash",
+			identity: "tenant-a",
+			key:      "asm://eu-west-1/tenant-a/db/",
+			allowed:  true,
+		},
+		{
+			name:     "HostCase",
+			identity: "tenant-b",
+			key:      "asm://EU-WEST-1/tenant-b/x/key",
+			allowed:  true,
+		},
+		{
+			name:     "SchemeCase",
+			identity: "tenant-a",
+			key:      "ASM://eu-west-1/tenant-a/db",
+			allowed:  true,
+		},
+		{
+			name: "NoIdentity",
+			key:  "asm://eu-west-1/tenant-a/db",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key, err := url.Parse(test.key)
+			require.NoError(t, err)
+			require.Equal(t, test.allowed, accessPolicy.Allowed(test.identity, key))
+		})
+	}
+}
+
+func TestDenyBypass(t *testing.T) {
+	accessPolicy, err := policy.New([]*policy.Rule{
+		{Effect: policy.EffectAllow},
+		{Effect: policy.EffectDeny, Path: "etc/secrets/tenant-b/**"},
+		{Effect: policy.EffectDeny, Scheme: "asm", Host: "eu-west-1"},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		key     string
+		allowed bool
+	}{
+		{
+			name: "Denied",
+			key:  "file:///etc/secrets/tenant-b/key",
+		},
+		{
+			name: "EmptySegmentPrefix",
+			key:  "file:///etc//secrets/tenant-b/key",
+		},
+		{
+			name: "EmptySegmentMiddle",
+			key:  "file:///etc/secrets//tenant-b/key",
+		},
+		{
+			name: "EncodedSlash",
+			key:  "file:///etc/secrets/%2Ftenant-b/key",
+		},
+		{
+			name: "HostCase",
+			key:  "asm://EU-WEST-1/x",
+		},
+		{
+			name:    "Allowed",
+			key:     "file:///etc/secrets/tenant-a/key",
+			allowed: true,
+		},
+		{
+			name:    "NoPath",
+			key:     "asm://us-east-1",
+			allowed: true,
+		},
+		{
+			name: "DefaultHost",
+			key:  "asm:///x",
+		},
+		{
+			name:    "DefaultHostOtherScheme",
+			key:     "gsm:///x",
+			allowed: true,
+		},
+		{
+			name:    "TrailingSlash",
+			key:     "file:///etc/secrets/tenant-a/",
+			allowed: true,
+		},
+		{
+			name: "DotSegment",
+			key:  "file:///etc/secrets/./tenant-b/key",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key, err := url.Parse(test.key)
+			require.NoError(t, err)
+			require.Equal(t, test.allowed, accessPolicy.Allowed("tenant-a", key))
+		})
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "policy")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = policy.LoadFile(filepath.Join(dir, "missing.yaml"))
+	require.Error(t, err)
+
+	path := filepath.Join(dir, "policy.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`
+rules:
+  - effect: allow
+    identities: ["tenant-a"]
+    scheme: asm
+    path: "tenant-a/**"
+  - effect: deny
+    path: "**/private"
+`), 0600))
+	accessPolicy, err := policy.LoadFile(path)
+	require.NoError(t, err)
+	key, err := url.Parse("asm://eu-west-1/tenant-a/db")
+	require.NoError(t, err)
+	require.True(t, accessPolicy.Allowed("tenant-a", key))
+	require.False(t, accessPolicy.Allowed("tenant-b", key))
+
+	// JSON is also accepted.
+	path = filepath.Join(dir, "policy.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"rules":[{"effect":"allow","scheme":"asm"}]}`), 0600))
+	accessPolicy, err = policy.LoadFile(path)
+	require.NoError(t, err)
+	require.True(t, accessPolicy.Allowed("tenant-b", key))
+
+	// Unknown fields are rejected.
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"rules":[{"effect":"allow","schema":"asm"}]}`), 0600))
+	_, err = policy.LoadFile(path)
+	require.Error(t, err)
+
+	// Invalid rules are rejected.
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"rules":[{"effect":"permit"}]}`), 0600))
+	_, err = policy.LoadFile(path)
+	require.EqualError(t, err, `invalid rule 0: effect "permit" unknown`)
+}
+
+func TestContextIdentity(t *testing.T) {
+	ctx := context.Background()
+	require.Equal(t, "", policy.ContextIdentity(ctx))
+
+	ctx = context.WithValue(ctx, &policy.Identity{}, "tenant-a")
+	require.Equal(t, "tenant-a", policy.ContextIdentity(ctx))
+}
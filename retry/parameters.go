@@ -0,0 +1,146 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	majordomo "github.com/wealdtech/go-majordomo"
+)
+
+type parameters struct {
+	logLevel        zerolog.Level
+	majordomo       majordomo.Service
+	maxAttempts     int
+	initialInterval time.Duration
+	maxInterval     time.Duration
+	multiplier      float64
+	jitter          float64
+	maxElapsedTime  time.Duration
+}
+
+// Parameter is the interface for service parameters.
+type Parameter interface {
+	apply(*parameters)
+}
+
+type parameterFunc func(*parameters)
+
+func (f parameterFunc) apply(p *parameters) {
+	f(p)
+}
+
+// WithLogLevel sets the log level for the module.
+func WithLogLevel(logLevel zerolog.Level) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.logLevel = logLevel
+	})
+}
+
+// WithMajordomo sets the majordomo service whose fetches are retried.
+func WithMajordomo(service majordomo.Service) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.majordomo = service
+	})
+}
+
+// WithMaxAttempts sets the maximum number of attempts to fetch a value,
+// including the first.
+func WithMaxAttempts(maxAttempts int) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.maxAttempts = maxAttempts
+	})
+}
+
+// WithInitialInterval sets the interval between the first and second attempts.
+func WithInitialInterval(interval time.Duration) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.initialInterval = interval
+	})
+}
+
+// WithMaxInterval sets the maximum interval between attempts.
+func WithMaxInterval(interval time.Duration) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.maxInterval = interval
+	})
+}
+
+// WithMultiplier sets the factor by which the interval increases after each attempt.
+func WithMultiplier(multiplier float64) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.multiplier = multiplier
+	})
+}
+
+// WithJitter sets the randomization of each interval, as a fraction of the interval.
+// For example a jitter of 0.5 with an interval of 1s results in an interval
+// between 0.5s and 1.5s.  A jitter of 0 disables randomization.
+func WithJitter(jitter float64) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.jitter = jitter
+	})
+}
+
+// WithMaxElapsedTime sets the maximum time spent fetching a value, after which
+// no further attempts are made.  A maximum elapsed time of 0 disables the limit.
+func WithMaxElapsedTime(maxElapsedTime time.Duration) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.maxElapsedTime = maxElapsedTime
+	})
+}
+
+// parseAndCheckParameters parses and checks parameters to ensure that mandatory parameters are present and correct.
+func parseAndCheckParameters(params ...Parameter) (*parameters, error) {
+	parameters := parameters{
+		logLevel:        zerolog.GlobalLevel(),
+		maxAttempts:     5,
+		initialInterval: 100 * time.Millisecond,
+		maxInterval:     5 * time.Second,
+		multiplier:      2,
+		jitter:          0.5,
+		maxElapsedTime:  30 * time.Second,
+	}
+	for _, p := range params {
+		if params != nil {
+			p.apply(&parameters)
+		}
+	}
+
+	if parameters.majordomo == nil {
+		return nil, errors.New("no majordomo specified")
+	}
+	if parameters.maxAttempts <= 0 {
+		return nil, errors.New("max attempts must be greater than 0")
+	}
+	if parameters.initialInterval <= 0 {
+		return nil, errors.New("initial interval must be greater than 0")
+	}
+	if parameters.maxInterval < parameters.initialInterval {
+		return nil, errors.New("max interval cannot be less than initial interval")
+	}
+	if parameters.multiplier < 1 {
+		return nil, errors.New("multiplier cannot be less than 1")
+	}
+	if parameters.jitter < 0 || parameters.jitter > 1 {
+		return nil, errors.New("jitter must be between 0 and 1")
+	}
+	if parameters.maxElapsedTime < 0 {
+		return nil, errors.New("max elapsed time cannot be negative")
+	}
+
+	return &parameters, nil
+}
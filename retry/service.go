@@ -0,0 +1,154 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	zerologger "github.com/rs/zerolog/log"
+	majordomo "github.com/wealdtech/go-majordomo"
+)
+
+// Service is a majordomo service that retries failed fetches of another
+// majordomo service with exponential backoff.
+// Definitive errors, such as majordomo.ErrNotFound, are returned immediately.
+// When no further attempts can be made the error from the last attempt is returned.
+type Service struct {
+	majordomo       majordomo.Service
+	maxAttempts     int
+	initialInterval time.Duration
+	maxInterval     time.Duration
+	multiplier      float64
+	jitter          float64
+	maxElapsedTime  time.Duration
+
+	randMu sync.Mutex
+	rand   *rand.Rand
+}
+
+// module-wide log.
+var log zerolog.Logger
+
+// definitiveErrors are errors for which a retry will not succeed.
+var definitiveErrors = []error{
+	majordomo.ErrNotFound,
+	majordomo.ErrURLInvalid,
+	majordomo.ErrSchemeUnknown,
+	majordomo.ErrNotSupported,
+	majordomo.ErrValueTooLarge,
+	majordomo.ErrPermissionDenied,
+	majordomo.ErrInvalidValue,
+	majordomo.ErrNotReference,
+}
+
+// New creates a new retrying majordomo service.
+func New(ctx context.Context, params ...Parameter) (*Service, error) {
+	parameters, err := parseAndCheckParameters(params...)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with parameters")
+	}
+
+	// Set logging.
+	log = zerologger.With().Str("service", "majordomo").Str("impl", "retry").Logger()
+	if parameters.logLevel != log.GetLevel() {
+		log = log.Level(parameters.logLevel)
+	}
+
+	s := &Service{
+		majordomo:       parameters.majordomo,
+		maxAttempts:     parameters.maxAttempts,
+		initialInterval: parameters.initialInterval,
+		maxInterval:     parameters.maxInterval,
+		multiplier:      parameters.multiplier,
+		jitter:          parameters.jitter,
+		maxElapsedTime:  parameters.maxElapsedTime,
+		rand:            rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	return s, nil
+}
+
+// Fetch fetches a value given its key, retrying on failure.
+// No attempt is made that would start after the context's deadline.
+func (s *Service) Fetch(ctx context.Context, key string) ([]byte, error) {
+	started := time.Now()
+	interval := s.initialInterval
+	for attempt := 1; ; attempt++ {
+		value, err := s.majordomo.Fetch(ctx, key)
+		if err == nil {
+			return value, nil
+		}
+		if !retryable(err) {
+			return nil, err
+		}
+		if attempt >= s.maxAttempts {
+			log.Debug().Err(err).Int("attempts", attempt).Msg("Maximum attempts reached")
+			return nil, err
+		}
+
+		delay := s.randomize(interval)
+		if s.maxElapsedTime > 0 && time.Since(started)+delay > s.maxElapsedTime {
+			log.Debug().Err(err).Int("attempts", attempt).Msg("Maximum elapsed time reached")
+			return nil, err
+		}
+		if deadline, exists := ctx.Deadline(); exists && time.Now().Add(delay).After(deadline) {
+			log.Debug().Err(err).Int("attempts", attempt).Msg("Context deadline reached")
+			return nil, err
+		}
+
+		log.Trace().Err(err).Int("attempt", attempt).Dur("delay", delay).Msg("Fetch failed; retrying")
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		}
+
+		interval = time.Duration(float64(interval) * s.multiplier)
+		if interval > s.maxInterval {
+			interval = s.maxInterval
+		}
+	}
+}
+
+// retryable returns true if a fetch that failed with the error could succeed
+// if retried.
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	for _, definitiveErr := range definitiveErrors {
+		if errors.Is(err, definitiveErr) {
+			return false
+		}
+	}
+	return true
+}
+
+// randomize applies jitter to an interval.
+func (s *Service) randomize(interval time.Duration) time.Duration {
+	if s.jitter == 0 {
+		return interval
+	}
+	s.randMu.Lock()
+	factor := 1 - s.jitter + 2*s.jitter*s.rand.Float64()
+	s.randMu.Unlock()
+	return time.Duration(float64(interval) * factor)
+}
@@ -0,0 +1,271 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	majordomo "github.com/wealdtech/go-majordomo"
+	"github.com/wealdtech/go-majordomo/retry"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []retry.Parameter
+		err    string
+	}{
+		{
+			name: "MajordomoMissing",
+			err:  "problem with parameters: no majordomo specified",
+		},
+		{
+			name:   "MaxAttemptsZero",
+			params: []retry.Parameter{retry.WithMajordomo(&MockMajordomo{}), retry.WithMaxAttempts(0)},
+			err:    "problem with parameters: max attempts must be greater than 0",
+		},
+		{
+			name:   "InitialIntervalZero",
+			params: []retry.Parameter{retry.WithMajordomo(&MockMajordomo{}), retry.WithInitialInterval(0)},
+			err:    "problem with parameters: initial interval must be greater than 0",
+		},
+		{
+			name:   "MaxIntervalLow",
+			params: []retry.Parameter{retry.WithMajordomo(&MockMajordomo{}), retry.WithInitialInterval(time.Second), retry.WithMaxInterval(time.Millisecond)},
+			err:    "problem with parameters: max interval cannot be less than initial interval",
+		},
+		{
+			name:   "MultiplierLow",
+			params: []retry.Parameter{retry.WithMajordomo(&MockMajordomo{}), retry.WithMultiplier(0.5)},
+			err:    "problem with parameters: multiplier cannot be less than 1",
+		},
+		{
+			name:   "JitterHigh",
+			params: []retry.Parameter{retry.WithMajordomo(&MockMajordomo{}), retry.WithJitter(1.5)},
+			err:    "problem with parameters: jitter must be between 0 and 1",
+		},
+		{
+			name:   "MaxElapsedTimeNegative",
+			params: []retry.Parameter{retry.WithMajordomo(&MockMajordomo{}), retry.WithMaxElapsedTime(-time.Second)},
+			err:    "problem with parameters: max elapsed time cannot be negative",
+		},
+		{
+			name:   "Good",
+			params: []retry.Parameter{retry.WithMajordomo(&MockMajordomo{})},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			params := append([]retry.Parameter{retry.WithLogLevel(zerolog.Disabled)}, test.params...)
+			_, err := retry.New(context.Background(), params...)
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestFetch(t *testing.T) {
+	tests := []struct {
+		name     string
+		errs     []error
+		attempts int
+		err      error
+	}{
+		{
+			name:     "Immediate",
+			attempts: 1,
+		},
+		{
+			name:     "Transient",
+			errs:     []error{majordomo.ErrUnavailable, majordomo.NewError(majordomo.ErrTimeout, errors.New("slow"))},
+			attempts: 3,
+		},
+		{
+			name:     "Unclassified",
+			errs:     []error{errors.New("connection reset")},
+			attempts: 2,
+		},
+		{
+			name:     "Exhausted",
+			errs:     []error{majordomo.ErrUnavailable, majordomo.ErrUnavailable, majordomo.ErrUnavailable, majordomo.ErrUnavailable},
+			attempts: 3,
+			err:      majordomo.ErrUnavailable,
+		},
+		{
+			name:     "NotFound",
+			errs:     []error{majordomo.ErrNotFound},
+			attempts: 1,
+			err:      majordomo.ErrNotFound,
+		},
+		{
+			name:     "NotFoundWrapped",
+			errs:     []error{majordomo.NewError(majordomo.ErrNotFound, errors.New("no such secret"))},
+			attempts: 1,
+			err:      majordomo.ErrNotFound,
+		},
+		{
+			name:     "URLInvalid",
+			errs:     []error{majordomo.ErrURLInvalid},
+			attempts: 1,
+			err:      majordomo.ErrURLInvalid,
+		},
+		{
+			name:     "SchemeUnknown",
+			errs:     []error{majordomo.ErrSchemeUnknown},
+			attempts: 1,
+			err:      majordomo.ErrSchemeUnknown,
+		},
+		{
+			name:     "PermissionDenied",
+			errs:     []error{majordomo.ErrUnavailable, majordomo.ErrPermissionDenied},
+			attempts: 2,
+			err:      majordomo.ErrPermissionDenied,
+		},
+	}
+
+	ctx := context.Background()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mock := &MockMajordomo{errs: test.errs}
+			service, err := retry.New(ctx,
+				retry.WithLogLevel(zerolog.Disabled),
+				retry.WithMajordomo(mock),
+				retry.WithMaxAttempts(3),
+				retry.WithInitialInterval(time.Millisecond),
+			)
+			require.NoError(t, err)
+			value, err := service.Fetch(ctx, "mock:///key")
+			if test.err != nil {
+				require.True(t, errors.Is(err, test.err), err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, []byte("value"), value)
+			}
+			require.Equal(t, test.attempts, mock.Attempts())
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	ctx := context.Background()
+	mock := &MockMajordomo{errs: []error{majordomo.ErrUnavailable, majordomo.ErrUnavailable, majordomo.ErrUnavailable}}
+	service, err := retry.New(ctx,
+		retry.WithLogLevel(zerolog.Disabled),
+		retry.WithMajordomo(mock),
+		retry.WithInitialInterval(20*time.Millisecond),
+		retry.WithMaxInterval(50*time.Millisecond),
+		retry.WithMultiplier(2),
+		retry.WithJitter(0),
+	)
+	require.NoError(t, err)
+
+	started := time.Now()
+	_, err = service.Fetch(ctx, "mock:///key")
+	require.NoError(t, err)
+	// Intervals of 20ms, 40ms and 50ms (capped).
+	require.GreaterOrEqual(t, int64(time.Since(started)), int64(110*time.Millisecond))
+	require.Equal(t, 4, mock.Attempts())
+}
+
+func TestMaxElapsedTime(t *testing.T) {
+	ctx := context.Background()
+	mock := &MockMajordomo{errs: []error{majordomo.ErrUnavailable, majordomo.ErrUnavailable, majordomo.ErrUnavailable}}
+	service, err := retry.New(ctx,
+		retry.WithLogLevel(zerolog.Disabled),
+		retry.WithMajordomo(mock),
+		retry.WithInitialInterval(40*time.Millisecond),
+		retry.WithJitter(0),
+		retry.WithMaxElapsedTime(100*time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	// Attempts at 0ms and 40ms; the next would be at 120ms.
+	_, err = service.Fetch(ctx, "mock:///key")
+	require.True(t, errors.Is(err, majordomo.ErrUnavailable))
+	require.Equal(t, 2, mock.Attempts())
+}
+
+func TestContextDeadline(t *testing.T) {
+	mock := &MockMajordomo{errs: []error{majordomo.ErrUnavailable, majordomo.ErrUnavailable, majordomo.ErrUnavailable}}
+	service, err := retry.New(context.Background(),
+		retry.WithLogLevel(zerolog.Disabled),
+		retry.WithMajordomo(mock),
+		retry.WithInitialInterval(time.Second),
+	)
+	require.NoError(t, err)
+
+	// The delay before the second attempt is beyond the deadline, so return immediately.
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	_, err = service.Fetch(ctx, "mock:///key")
+	require.True(t, errors.Is(err, majordomo.ErrUnavailable))
+	require.Less(t, int64(time.Since(started)), int64(100*time.Millisecond))
+	require.Equal(t, 1, mock.Attempts())
+}
+
+func TestContextCancelled(t *testing.T) {
+	mock := &MockMajordomo{errs: []error{majordomo.ErrUnavailable, majordomo.ErrUnavailable, majordomo.ErrUnavailable}}
+	service, err := retry.New(context.Background(),
+		retry.WithLogLevel(zerolog.Disabled),
+		retry.WithMajordomo(mock),
+		retry.WithInitialInterval(time.Second),
+	)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	started := time.Now()
+	_, err = service.Fetch(ctx, "mock:///key")
+	require.True(t, errors.Is(err, majordomo.ErrUnavailable))
+	require.Less(t, int64(time.Since(started)), int64(500*time.Millisecond))
+	require.Equal(t, 1, mock.Attempts())
+}
+
+// MockMajordomo is a mock majordomo service that returns the supplied errors in
+// turn, and then a value.
+type MockMajordomo struct {
+	mu       sync.Mutex
+	errs     []error
+	attempts int
+}
+
+func (m *MockMajordomo) Fetch(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.attempts++
+	if m.attempts <= len(m.errs) {
+		return nil, m.errs[m.attempts-1]
+	}
+	return []byte("value"), nil
+}
+
+func (m *MockMajordomo) Attempts() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.attempts
+}
@@ -0,0 +1,96 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package majordomo
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/rs/zerolog"
+	"github.com/wealdtech/go-majordomo/internal/memory"
+)
+
+// redacted is the text output in place of a secret value.
+const redacted = "[REDACTED]"
+
+// Secret holds a secret value.
+// The value is zeroed when the secret is destroyed, so it should be
+// destroyed as soon as it is no longer required.  The value is redacted when
+// the secret is formatted with the fmt package, marshalled to JSON, or
+// logged with zerolog.
+type Secret struct {
+	mu    sync.RWMutex
+	value []byte
+}
+
+// NewSecret creates a new secret.
+// The secret takes ownership of the supplied value, which will be zeroed
+// when the secret is destroyed; the caller should not retain it.
+func NewSecret(value []byte) *Secret {
+	return &Secret{
+		value: value,
+	}
+}
+
+// Bytes returns the value of the secret.
+// The returned slice shares its backing array with the secret, so it will be
+// zeroed when the secret is destroyed.  It returns nil if the secret has been
+// destroyed.
+func (s *Secret) Bytes() []byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.value
+}
+
+// String returns the value of the secret as a string.
+// N.B. strings are immutable, so the returned string is a copy of the value
+// that will not be zeroed when the secret is destroyed.  Bytes() should be
+// used in preference where possible.
+func (s *Secret) String() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return string(s.value)
+}
+
+// Destroy zeroes the value of the secret.
+// The secret cannot be used after it has been destroyed.
+func (s *Secret) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	memory.Zero(s.value)
+	s.value = nil
+}
+
+// Format implements fmt.Formatter, redacting the value.
+func (s *Secret) Format(f fmt.State, _ rune) {
+	_, _ = f.Write([]byte(redacted))
+}
+
+// MarshalJSON implements json.Marshaler, redacting the value.
+func (s *Secret) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + redacted + `"`), nil
+}
+
+// MarshalText implements encoding.TextMarshaler, redacting the value.
+func (s *Secret) MarshalText() ([]byte, error) {
+	return []byte(redacted), nil
+}
+
+// MarshalZerologObject implements zerolog.LogObjectMarshaler, redacting the value.
+func (s *Secret) MarshalZerologObject(e *zerolog.Event) {
+	e.Str("value", redacted)
+}
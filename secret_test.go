@@ -0,0 +1,55 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package majordomo_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	majordomo "github.com/wealdtech/go-majordomo"
+)
+
+func TestSecret(t *testing.T) {
+	value := []byte("secret value")
+	secret := majordomo.NewSecret(value)
+
+	require.Equal(t, []byte("secret value"), secret.Bytes())
+	require.Equal(t, "secret value", secret.String())
+
+	// Redaction.
+	require.Equal(t, "[REDACTED]", fmt.Sprintf("%v", secret))
+	require.Equal(t, "[REDACTED]", fmt.Sprintf("%s", secret))
+	require.Equal(t, "[REDACTED]", fmt.Sprintf("%#v", secret))
+	require.Equal(t, "[REDACTED]", fmt.Sprint(secret))
+	data, err := json.Marshal(struct {
+		Secret *majordomo.Secret `json:"secret"`
+	}{
+		Secret: secret,
+	})
+	require.NoError(t, err)
+	require.Equal(t, `{"secret":"[REDACTED]"}`, string(data))
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+	logger.Info().Object("secret", secret).Interface("value", secret).Msg("")
+	require.NotContains(t, buf.String(), "secret value")
+
+	// Destruction.
+	secret.Destroy()
+	require.Nil(t, secret.Bytes())
+	require.Equal(t, make([]byte, len(value)), value)
+}
@@ -0,0 +1,212 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package standard
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+	majordomo "github.com/wealdtech/go-majordomo"
+	"github.com/wealdtech/go-majordomo/internal/memory"
+	"gopkg.in/yaml.v3"
+)
+
+// PayloadFormat is a context tag for the format of values from which fields
+// are selected, as a string: one of "json", "yaml" or "toml".  If not supplied
+// the format is obtained from the extension of the key's path if present,
+// otherwise it is detected from the value.
+type PayloadFormat struct{}
+
+// selectField selects a field from a structured value.
+// The selector is either the name of a top-level field, for example
+// "password", or a JSON pointer, for example "/nested/field".
+// The supplied value is zeroed.
+func selectField(ctx context.Context, url *url.URL, selector string, data []byte) ([]byte, error) {
+	defer memory.Zero(data)
+
+	tokens := selectorTokens(selector)
+
+	doc, err := decodePayload(payloadFormat(ctx, url), data)
+	if err != nil {
+		return nil, majordomo.NewError(majordomo.ErrInvalidValue, err)
+	}
+
+	for _, token := range tokens {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			doc = node[token]
+		case map[interface{}]interface{}:
+			doc = node[token]
+		case []interface{}:
+			index, err := arrayIndex(token)
+			if err != nil || index >= len(node) {
+				return nil, majordomo.ErrNotFound
+			}
+			doc = node[index]
+		default:
+			return nil, majordomo.ErrNotFound
+		}
+	}
+	if doc == nil {
+		// Null values are treated as missing.
+		return nil, majordomo.ErrNotFound
+	}
+
+	return encodeField(doc)
+}
+
+// selectorTokens breaks a selector in to its reference tokens.
+func selectorTokens(selector string) []string {
+	if !strings.HasPrefix(selector, "/") {
+		return []string{selector}
+	}
+
+	// JSON pointer, as per RFC 6901.
+	tokens := strings.Split(selector[1:], "/")
+	for i := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(tokens[i], "~1", "/"), "~0", "~")
+	}
+	return tokens
+}
+
+// arrayIndex parses a JSON pointer array index.
+func arrayIndex(token string) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, errors.New("invalid array index")
+	}
+	for _, c := range token {
+		if c < '0' || c > '9' {
+			return 0, errors.New("invalid array index")
+		}
+	}
+	return strconv.Atoi(token)
+}
+
+// payloadFormat obtains the declared format of the payload, if any.
+func payloadFormat(ctx context.Context, url *url.URL) string {
+	if format, exists := ctx.Value(&PayloadFormat{}).(string); exists {
+		return strings.ToLower(format)
+	}
+
+	switch strings.ToLower(filepath.Ext(url.Path)) {
+	case ".json":
+		return "json"
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".toml":
+		return "toml"
+	default:
+		return ""
+	}
+}
+
+// decodePayload decodes a payload in the given format.  If the format is not
+// supplied then JSON, TOML and YAML are attempted in that order, as JSON is
+// the strictest and YAML the most permissive.
+func decodePayload(format string, data []byte) (interface{}, error) {
+	var doc interface{}
+	switch format {
+	case "json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		// Use numbers to retain the original representation.
+		decoder.UseNumber()
+		if err := decoder.Decode(&doc); err != nil {
+			return nil, errors.Wrap(err, "invalid JSON")
+		}
+	case "yaml":
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, errors.Wrap(err, "invalid YAML")
+		}
+	case "toml":
+		table := make(map[string]interface{})
+		if err := toml.Unmarshal(data, &table); err != nil {
+			return nil, errors.Wrap(err, "invalid TOML")
+		}
+		doc = table
+	case "":
+		if json.Valid(data) {
+			return decodePayload("json", data)
+		}
+		if doc, err := decodePayload("toml", data); err == nil {
+			return doc, nil
+		}
+		doc, err := decodePayload("yaml", data)
+		if err != nil {
+			return nil, errors.New("value is not JSON, YAML or TOML")
+		}
+		return doc, nil
+	default:
+		return nil, fmt.Errorf("unsupported payload format %s", format)
+	}
+
+	return doc, nil
+}
+
+// encodeField encodes a selected field.
+// Strings are returned as-is, other scalars as their textual representation,
+// and objects and arrays as JSON.
+func encodeField(field interface{}) ([]byte, error) {
+	switch value := field.(type) {
+	case string:
+		return []byte(value), nil
+	case json.Number:
+		return []byte(value.String()), nil
+	case bool, int, int64, uint64, float64:
+		return []byte(fmt.Sprintf("%v", value)), nil
+	case time.Time:
+		return []byte(value.Format(time.RFC3339Nano)), nil
+	default:
+		data, err := json.Marshal(jsonCompatible(value))
+		if err != nil {
+			return nil, majordomo.NewError(majordomo.ErrInvalidValue, errors.Wrap(err, "failed to encode field"))
+		}
+		return data, nil
+	}
+}
+
+// jsonCompatible converts maps with non-string keys, as generated by some YAML
+// documents, to maps with string keys so that they can be encoded as JSON.
+func jsonCompatible(value interface{}) interface{} {
+	switch node := value.(type) {
+	case map[interface{}]interface{}:
+		res := make(map[string]interface{}, len(node))
+		for k, v := range node {
+			res[fmt.Sprintf("%v", k)] = jsonCompatible(v)
+		}
+		return res
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(node))
+		for k, v := range node {
+			res[k] = jsonCompatible(v)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(node))
+		for i, v := range node {
+			res[i] = jsonCompatible(v)
+		}
+		return res
+	default:
+		return value
+	}
+}
@@ -14,11 +14,27 @@
 package standard
 
 import (
+	"time"
+
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
+	"github.com/wealdtech/go-majordomo/audit"
+	"github.com/wealdtech/go-majordomo/metrics"
+	"github.com/wealdtech/go-majordomo/policy"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type parameters struct {
-	logLevel zerolog.Level
+	logLevel       zerolog.Level
+	watchInterval  time.Duration
+	maxStreamSize  int64
+	parallelism    int
+	strict         bool
+	metrics        metrics.Service
+	tracerProvider trace.TracerProvider
+	auditSink      audit.Sink
+	auditKeyFormat audit.KeyFormat
+	policy         *policy.Policy
 }
 
 // Parameter is the interface for service parameters.
@@ -39,10 +55,85 @@ func WithLogLevel(logLevel zerolog.Level) Parameter {
 	})
 }
 
+// WithWatchInterval sets the interval at which values are polled when watched.
+func WithWatchInterval(interval time.Duration) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.watchInterval = interval
+	})
+}
+
+// WithMaxStreamSize sets the maximum size of values returned by FetchStream.
+// A size of 0 means that there is no limit.
+func WithMaxStreamSize(size int64) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.maxStreamSize = size
+	})
+}
+
+// WithParallelism sets the maximum number of concurrent fetches made by FetchMany.
+func WithParallelism(parallelism int) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.parallelism = parallelism
+	})
+}
+
+// WithStrict sets strict mode.  In strict mode keys must be references to
+// values held by confidants; keys that would otherwise be returned as literal
+// values are rejected with majordomo.ErrNotReference.
+func WithStrict(strict bool) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.strict = strict
+	})
+}
+
+// WithMetrics sets the metrics service to which fetches and registered
+// confidants are reported.
+func WithMetrics(metrics metrics.Service) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.metrics = metrics
+	})
+}
+
+// WithTracerProvider sets the OpenTelemetry tracer provider used to create
+// spans for fetches.  If not supplied no spans are created.
+func WithTracerProvider(provider trace.TracerProvider) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.tracerProvider = provider
+	})
+}
+
+// WithAuditSink sets the sink to which an audit event is sent for each attempt
+// to fetch a value from a confidant.
+func WithAuditSink(sink audit.Sink) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.auditSink = sink
+	})
+}
+
+// WithAuditKeyFormat sets the format in which keys are recorded in audit events.
+// Defaults to audit.KeyFormatRedacted.
+func WithAuditKeyFormat(format audit.KeyFormat) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.auditKeyFormat = format
+	})
+}
+
+// WithPolicy sets the access policy that decides which keys a caller may
+// access.  The caller is identified by the policy.Identity context value.
+// If not supplied all keys may be accessed.
+func WithPolicy(policy *policy.Policy) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.policy = policy
+	})
+}
+
 // parseAndCheckParameters parses and checks parameters to ensure that mandatory parameters are present and correct.
 func parseAndCheckParameters(params ...Parameter) (*parameters, error) {
 	parameters := parameters{
-		logLevel: zerolog.GlobalLevel(),
+		logLevel:      zerolog.GlobalLevel(),
+		watchInterval: time.Minute,
+		maxStreamSize: 64 * 1024 * 1024,
+		parallelism:   16,
 	}
 	for _, p := range params {
 		if params != nil {
@@ -50,5 +141,21 @@ func parseAndCheckParameters(params ...Parameter) (*parameters, error) {
 		}
 	}
 
+	if parameters.watchInterval <= 0 {
+		return nil, errors.New("watch interval must be greater than 0")
+	}
+
+	if parameters.maxStreamSize < 0 {
+		return nil, errors.New("max stream size cannot be negative")
+	}
+
+	if parameters.parallelism <= 0 {
+		return nil, errors.New("parallelism must be greater than 0")
+	}
+
+	if parameters.auditKeyFormat != audit.KeyFormatRedacted && parameters.auditKeyFormat != audit.KeyFormatHashed {
+		return nil, errors.New("audit key format unknown")
+	}
+
 	return &parameters, nil
 }
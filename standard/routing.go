@@ -0,0 +1,190 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package standard
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	majordomo "github.com/wealdtech/go-majordomo"
+)
+
+// schemeRegex matches valid URL schemes.
+// url.Parse() lower-cases schemes, so only lower-case schemes can be matched.
+var schemeRegex = regexp.MustCompile(`^[a-z][a-z0-9+.-]*$`)
+
+// route routes requests to a confidant.
+type route struct {
+	// scheme is the scheme of requests handled by the route.
+	scheme string
+	// prefix is the prefix of the host and path of requests handled by the route.
+	// An empty prefix handles all requests for the scheme.
+	prefix string
+	// target is the scheme of the URL passed to the confidant.
+	target string
+	// confidant is the confidant that handles requests.
+	confidant majordomo.Confidant
+}
+
+// String provides a human-readable description of the route.
+func (r *route) String() string {
+	if r.prefix == "" {
+		return r.scheme
+	}
+	return fmt.Sprintf("%s://%s", r.scheme, r.prefix)
+}
+
+// matches returns true if the route handles the URL.
+func (r *route) matches(url *url.URL) bool {
+	return strings.HasPrefix(url.Host+url.Path, r.prefix)
+}
+
+// conflict returns an error describing a conflict with an existing route.
+func (r *route) conflict() error {
+	if r.prefix == "" {
+		return fmt.Errorf("scheme %s already registered by another confidant", r.scheme)
+	}
+	return fmt.Errorf("scheme %s with prefix %s already registered by another confidant", r.scheme, r.prefix)
+}
+
+// externalKey converts a key returned by the confidant to one that will be
+// routed back to the same confidant.
+func (r *route) externalKey(key string) string {
+	if r.scheme == r.target {
+		return key
+	}
+	return r.scheme + strings.TrimPrefix(key, r.target)
+}
+
+// RegistrationParameter is the interface for confidant registration parameters.
+type RegistrationParameter interface {
+	applyRegistration(*registrationParameters)
+}
+
+type registrationParameters struct {
+	routes []*route
+}
+
+type registrationParameterFunc func(*registrationParameters)
+
+func (f registrationParameterFunc) applyRegistration(p *registrationParameters) {
+	f(p)
+}
+
+// WithAlias registers the confidant for an alias scheme, allowing multiple
+// confidants that support the same scheme to be registered.  Requests for the
+// alias scheme are passed to the confidant with the scheme changed to the
+// given supported scheme, for example with WithAlias("gsm-prod", "gsm") the
+// request "gsm-prod://project/secret" is passed to the confidant as
+// "gsm://project/secret".
+func WithAlias(alias string, scheme string) RegistrationParameter {
+	return registrationParameterFunc(func(p *registrationParameters) {
+		p.routes = append(p.routes, &route{
+			scheme: alias,
+			target: scheme,
+		})
+	})
+}
+
+// WithRoute registers the confidant for requests with the given supported
+// scheme whose host and path start with the given prefix, for example with
+// WithRoute("gsm", "prod-project/") the confidant handles the request
+// "gsm://prod-project/secret".  Where multiple routes match a request, that
+// with the longest prefix is used.
+func WithRoute(scheme string, prefix string) RegistrationParameter {
+	return registrationParameterFunc(func(p *registrationParameters) {
+		p.routes = append(p.routes, &route{
+			scheme: scheme,
+			prefix: prefix,
+			target: scheme,
+		})
+	})
+}
+
+// routesForConfidant obtains the routes for a confidant.
+// If no routes are supplied in the parameters the confidant is routed all
+// requests for each of its supported schemes.
+// Confidants are compared to find those that handle multiple routes, so must
+// be of a comparable type such as a pointer.
+func routesForConfidant(ctx context.Context, confidant majordomo.Confidant, params ...RegistrationParameter) ([]*route, error) {
+	if confidant == nil {
+		return nil, errors.New("no confidant specified")
+	}
+	if !reflect.TypeOf(confidant).Comparable() {
+		return nil, errors.New("confidant is not comparable; supply a pointer")
+	}
+	schemes, err := confidant.SupportedURLSchemes(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to obtain supported URL schemes from confidant")
+	}
+
+	parameters := registrationParameters{}
+	for _, p := range params {
+		if p != nil {
+			p.applyRegistration(&parameters)
+		}
+	}
+
+	routes := parameters.routes
+	if len(routes) == 0 {
+		routes = make([]*route, 0, len(schemes))
+		for _, scheme := range schemes {
+			routes = append(routes, &route{
+				scheme: scheme,
+				target: scheme,
+			})
+		}
+	}
+
+	supported := make(map[string]bool, len(schemes))
+	for _, scheme := range schemes {
+		supported[scheme] = true
+	}
+	seen := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		if !schemeRegex.MatchString(route.scheme) {
+			return nil, fmt.Errorf("invalid scheme %s", route.scheme)
+		}
+		if !supported[route.target] {
+			return nil, fmt.Errorf("scheme %s not supported by confidant", route.target)
+		}
+		if seen[route.String()] {
+			return nil, fmt.Errorf("route %s supplied multiple times", route)
+		}
+		seen[route.String()] = true
+		route.confidant = confidant
+	}
+
+	return routes, nil
+}
+
+// addRoute returns a new set of routes containing the existing routes and
+// the new route, ordered by decreasing prefix length so that the longest
+// matching prefix is found first.  The existing set is not altered, as it
+// may be in use by concurrent requests.
+func addRoute(routes []*route, newRoute *route) []*route {
+	res := make([]*route, 0, len(routes)+1)
+	res = append(res, routes...)
+	res = append(res, newRoute)
+	sort.SliceStable(res, func(i, j int) bool {
+		return len(res[i].prefix) > len(res[j].prefix)
+	})
+	return res
+}
@@ -14,22 +14,53 @@
 package standard
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/url"
+	"os"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 	zerologger "github.com/rs/zerolog/log"
 	majordomo "github.com/wealdtech/go-majordomo"
+	"github.com/wealdtech/go-majordomo/audit"
+	"github.com/wealdtech/go-majordomo/internal/poll"
+	"github.com/wealdtech/go-majordomo/internal/redact"
+	"github.com/wealdtech/go-majordomo/internal/tracing"
+	"github.com/wealdtech/go-majordomo/metrics"
+	"github.com/wealdtech/go-majordomo/policy"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Service is the standard majordomo service.
 type Service struct {
-	confidants map[string]majordomo.Confidant
+	routesMu       sync.RWMutex
+	routes         map[string][]*route
+	watchInterval  time.Duration
+	maxStreamSize  int64
+	parallelism    int
+	strict         bool
+	metrics        metrics.Service
+	tracer         trace.Tracer
+	auditSink      audit.Sink
+	auditKeyFormat audit.KeyFormat
+	policy         *policy.Policy
 }
 
+// ReferenceRequired is a context tag that, when set to true, requires the key
+// for the call to be a reference to a value held by a confidant; literal keys
+// are rejected with majordomo.ErrNotReference.  For example:
+//
+//	ctx = context.WithValue(ctx, &standard.ReferenceRequired{}, true)
+type ReferenceRequired struct{}
+
 // module-wide log.
 var log zerolog.Logger
 
@@ -49,56 +80,953 @@ func New(ctx context.Context, params ...Parameter) (*Service, error) {
 	}
 
 	s := &Service{
-		confidants: make(map[string]majordomo.Confidant),
+		routes:         make(map[string][]*route),
+		watchInterval:  parameters.watchInterval,
+		maxStreamSize:  parameters.maxStreamSize,
+		parallelism:    parameters.parallelism,
+		strict:         parameters.strict,
+		metrics:        parameters.metrics,
+		tracer:         tracing.Tracer(parameters.tracerProvider),
+		auditSink:      parameters.auditSink,
+		auditKeyFormat: parameters.auditKeyFormat,
+		policy:         parameters.policy,
 	}
 
 	return s, nil
 }
 
 // RegisterConfidant registers a confidant.
-// The confidant will register whichever URL schemes it supports.
-func (s *Service) RegisterConfidant(ctx context.Context, confidant majordomo.Confidant) error {
-	schemes, err := confidant.SupportedURLSchemes(ctx)
+// By default the confidant will register whichever URL schemes it supports;
+// WithAlias() and WithRoute() can be supplied to register it for alias
+// schemes or for a subset of requests instead.
+// Registration fails, and no routes are registered, if any of the routes
+// is already registered by another confidant.
+// It is safe to register confidants while requests are in progress.
+func (s *Service) RegisterConfidant(ctx context.Context, confidant majordomo.Confidant, params ...RegistrationParameter) error {
+	routes, err := routesForConfidant(ctx, confidant, params...)
+	if err != nil {
+		return err
+	}
+
+	s.routesMu.Lock()
+	defer s.routesMu.Unlock()
+	for _, route := range routes {
+		for _, existing := range s.routes[route.scheme] {
+			if existing.prefix == route.prefix {
+				return route.conflict()
+			}
+		}
+	}
+	for _, route := range routes {
+		s.routes[route.scheme] = addRoute(s.routes[route.scheme], route)
+	}
+	s.reportConfidants()
+	return nil
+}
+
+// UnregisterConfidant unregisters a confidant from all of the routes for which it is registered.
+// The confidant is not closed.
+// It is safe to unregister confidants while requests are in progress.
+func (s *Service) UnregisterConfidant(ctx context.Context, confidant majordomo.Confidant) error {
+	s.routesMu.Lock()
+	defer s.routesMu.Unlock()
+
+	found := false
+	for scheme, routes := range s.routes {
+		remaining := make([]*route, 0, len(routes))
+		for _, route := range routes {
+			if route.confidant == confidant {
+				found = true
+			} else {
+				remaining = append(remaining, route)
+			}
+		}
+		if len(remaining) == 0 {
+			delete(s.routes, scheme)
+		} else {
+			s.routes[scheme] = remaining
+		}
+	}
+	if !found {
+		return errors.New("confidant not registered")
+	}
+	s.reportConfidants()
+	return nil
+}
+
+// ReplaceConfidant registers a confidant, atomically replacing any confidants
+// already registered for its routes.  Routes are as per RegisterConfidant().
+// Replaced confidants that are no longer registered for any route are closed
+// if they implement majordomo.Closer or io.Closer.  Requests to a replaced
+// confidant that are in progress when it is closed may fail.
+// It is safe to replace confidants while requests are in progress.
+func (s *Service) ReplaceConfidant(ctx context.Context, confidant majordomo.Confidant, params ...RegistrationParameter) error {
+	routes, err := routesForConfidant(ctx, confidant, params...)
 	if err != nil {
-		return errors.Wrap(err, "failed to obtain supported URL schemes from confidant")
+		return err
+	}
+
+	s.routesMu.Lock()
+	replaced := make(map[majordomo.Confidant]struct{})
+	for _, newRoute := range routes {
+		schemeRoutes := make([]*route, 0, len(s.routes[newRoute.scheme])+1)
+		for _, existing := range s.routes[newRoute.scheme] {
+			if existing.prefix != newRoute.prefix {
+				schemeRoutes = append(schemeRoutes, existing)
+				continue
+			}
+			if existing.confidant != confidant {
+				replaced[existing.confidant] = struct{}{}
+			}
+		}
+		s.routes[newRoute.scheme] = addRoute(schemeRoutes, newRoute)
+	}
+	// Only close confidants that are no longer registered for any route.
+	for _, schemeRoutes := range s.routes {
+		for _, route := range schemeRoutes {
+			delete(replaced, route.confidant)
+		}
 	}
-	for _, scheme := range schemes {
-		if _, exists := s.confidants[scheme]; exists {
-			return fmt.Errorf("scheme %s already registered by another confidant", scheme)
+	s.reportConfidants()
+	s.routesMu.Unlock()
+
+	for existing := range replaced {
+		if err := closeConfidant(ctx, existing); err != nil {
+			log.Warn().Err(err).Msg("Failed to close replaced confidant")
 		}
-		s.confidants[scheme] = confidant
 	}
+
 	return nil
 }
 
+// Close unregisters all confidants, and closes those that implement
+// majordomo.Closer or io.Closer.  All confidants are closed even if some
+// return errors; the first error encountered is returned.
+// The service can be reused by registering new confidants.
+func (s *Service) Close(ctx context.Context) error {
+	s.routesMu.Lock()
+	confidants := make([]majordomo.Confidant, 0)
+	seen := make(map[majordomo.Confidant]struct{})
+	for _, routes := range s.routes {
+		for _, route := range routes {
+			if _, exists := seen[route.confidant]; !exists {
+				seen[route.confidant] = struct{}{}
+				confidants = append(confidants, route.confidant)
+			}
+		}
+	}
+	s.routes = make(map[string][]*route)
+	s.reportConfidants()
+	s.routesMu.Unlock()
+
+	var res error
+	for _, confidant := range confidants {
+		if err := closeConfidant(ctx, confidant); err != nil {
+			log.Warn().Err(err).Msg("Failed to close confidant")
+			if res == nil {
+				res = errors.Wrap(err, "failed to close confidant")
+			}
+		}
+	}
+
+	return res
+}
+
+// closeConfidant closes a confidant if it is closable.
+func closeConfidant(ctx context.Context, confidant majordomo.Confidant) error {
+	switch closer := confidant.(type) {
+	case majordomo.Closer:
+		return closer.Close(ctx)
+	case io.Closer:
+		return closer.Close()
+	default:
+		return nil
+	}
+}
+
 // Fetch fetches a URL from a confidant.
+// Keys that are not URLs are returned as literal values, unless the service
+// is in strict mode or the context requires a reference.
+// If the URL has a fragment the value is treated as a JSON, YAML or TOML
+// document and only the field selected by the fragment is returned, for
+// example "asm://eu-west-1/db#password" returns the "password" field and
+// "asm://eu-west-1/db#/nested/field" the field given by the JSON pointer.
+// The format of the document is taken from the PayloadFormat context value or
+// the extension of the URL's path if available, otherwise it is detected.
+// If the field is not present majordomo.ErrNotFound is returned.
+// Transforms can be applied to the value, or selected field, with the
+// "transform" query parameter, which holds a comma-separated list of
+// transforms applied in order, for example "file:///etc/pass?transform=trim,base64decode".
+// The available transforms are "trim", "base64decode", "base64urldecode",
+// "hexdecode" and "gunzip".  Unknown transforms return majordomo.ErrURLInvalid.
+// If the service has a policy that does not allow the caller to access the key
+// an error of kind majordomo.ErrPermissionDenied, with cause policy.ErrDenied,
+// is returned.
+// If the service has an audit sink an event is recorded for each key that is
+// a reference, whether or not the fetch succeeds.
 func (s *Service) Fetch(ctx context.Context, req string) ([]byte, error) {
+	val, err := s.fetch(ctx, req)
+	s.audit(ctx, "Fetch", req, err)
+	return val, err
+}
+
+// fetch fetches a URL from a confidant, without auditing.
+func (s *Service) fetch(ctx context.Context, req string) ([]byte, error) {
 	// We short-circuit anything that isn't a URL as a direct value.
 	if req == "" {
 		// Empty req is never found.
 		return nil, majordomo.ErrNotFound
 	}
-	if !strings.Contains(req, "://") {
+	literal, err := s.isLiteral(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if literal {
 		return []byte(req), nil
 	}
 
+	url, route, err := s.authorizedRoute(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	processing, err := splitProcessing(url)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, span := tracing.Start(ctx, s.tracer, "majordomo.Fetch", route.scheme, confidantName(route.confidant))
+	started := time.Now()
+	val, err := route.confidant.Fetch(ctx, url)
+	if err == nil && processing.required() {
+		val, err = s.process(ctx, url, processing, val)
+	}
+	s.recordFetch(route, started, err)
+	tracing.End(span, err)
+	if err != nil {
+		return nil, confidantError(url, err)
+	}
+	return val, nil
+}
+
+// FetchSecret fetches a URL from a confidant, returning it as a secret.
+// The caller should destroy the secret once it is no longer required.
+func (s *Service) FetchSecret(ctx context.Context, req string) (*majordomo.Secret, error) {
+	val, err := s.Fetch(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return majordomo.NewSecret(val), nil
+}
+
+// FetchMany fetches multiple values.
+// The returned results are in the same order as the supplied keys, and each
+// contains either the value or the error encountered when fetching it.
+// Fetches are carried out concurrently, up to the service's parallelism.
+// Keys handled by a confidant that implements majordomo.BatchFetcher are
+// passed to the confidant in a single batch.
+func (s *Service) FetchMany(ctx context.Context, reqs []string) []*majordomo.FetchResult {
+	results := make([]*majordomo.FetchResult, len(reqs))
+
+	// Separate out the keys that can be fetched as a batch.
+	// The URL and route are kept, as routes can change before the batch is fetched.
+	batchConfidants := make([]majordomo.BatchFetcher, 0)
+	batches := make(map[majordomo.BatchFetcher][]*batchRequest)
+	singles := make([]int, 0, len(reqs))
+	for i, req := range reqs {
+		if strings.Contains(req, "://") {
+			if url, route, err := s.authorizedRoute(ctx, req); err == nil {
+				if batchFetcher, isBatchFetcher := route.confidant.(majordomo.BatchFetcher); isBatchFetcher {
+					if _, exists := batches[batchFetcher]; !exists {
+						batchConfidants = append(batchConfidants, batchFetcher)
+					}
+					batches[batchFetcher] = append(batches[batchFetcher], &batchRequest{
+						index: i,
+						url:   url,
+						route: route,
+					})
+					continue
+				}
+			}
+		}
+		singles = append(singles, i)
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, s.parallelism)
+	run := func(f func()) {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			f()
+		}()
+	}
+
+	for _, batchFetcher := range batchConfidants {
+		batchFetcher := batchFetcher
+		requests := batches[batchFetcher]
+		run(func() {
+			s.fetchBatch(ctx, batchFetcher, reqs, requests, results)
+		})
+	}
+	for _, i := range singles {
+		i := i
+		run(func() {
+			value, err := s.fetch(ctx, reqs[i])
+			s.audit(ctx, "FetchMany", reqs[i], err)
+			results[i] = &majordomo.FetchResult{
+				Value: value,
+				Err:   err,
+			}
+		})
+	}
+	wg.Wait()
+
+	return results
+}
+
+// batchRequest is a request in a batch, with the URL and route to which it resolved.
+type batchRequest struct {
+	index int
+	url   *url.URL
+	route *route
+}
+
+// fetchBatch fetches a batch of values from a batch fetcher, populating the
+// results for the given requests.
+func (s *Service) fetchBatch(ctx context.Context, batchFetcher majordomo.BatchFetcher, reqs []string, requests []*batchRequest, results []*majordomo.FetchResult) {
+	urls := make([]*url.URL, 0, len(requests))
+	routes := make([]*route, 0, len(requests))
+	processings := make([]*processing, 0, len(requests))
+	batchIndices := make([]int, 0, len(requests))
+	for _, request := range requests {
+		processing, err := splitProcessing(request.url)
+		if err != nil {
+			results[request.index] = &majordomo.FetchResult{Err: err}
+			s.audit(ctx, "FetchMany", reqs[request.index], err)
+			continue
+		}
+		urls = append(urls, request.url)
+		routes = append(routes, request.route)
+		processings = append(processings, processing)
+		batchIndices = append(batchIndices, request.index)
+	}
+	if len(urls) == 0 {
+		return
+	}
+
+	started := time.Now()
+	batchResults, err := batchFetcher.FetchBatch(ctx, urls)
+	if err == nil && len(batchResults) != len(urls) {
+		err = fmt.Errorf("confidant returned %d results for %d keys", len(batchResults), len(urls))
+	}
+	for i, index := range batchIndices {
+		switch {
+		case err != nil:
+			results[index] = &majordomo.FetchResult{Err: confidantError(urls[i], err)}
+		case batchResults[i] == nil:
+			results[index] = &majordomo.FetchResult{Err: confidantError(urls[i], majordomo.ErrNotFound)}
+		default:
+			value, err := batchResults[i].Value, batchResults[i].Err
+			if err == nil && processings[i].required() {
+				value, err = s.process(ctx, urls[i], processings[i], value)
+			}
+			results[index] = &majordomo.FetchResult{
+				Value: value,
+				Err:   confidantError(urls[i], err),
+			}
+		}
+		// Each key is recorded with the duration of the batch.
+		s.recordFetch(routes[i], started, results[index].Err)
+		s.audit(ctx, "FetchMany", reqs[index], results[index].Err)
+	}
+}
+
+// FetchWithMetadata fetches a URL from a confidant, along with metadata about the value.
+// Fields are selected and transforms applied as per Fetch(); the metadata is
+// that of the value held by the confidant.
+// If the confidant does not implement majordomo.MetadataFetcher the value is
+// returned with empty metadata.
+func (s *Service) FetchWithMetadata(ctx context.Context, req string) ([]byte, *majordomo.Metadata, error) {
+	val, metadata, err := s.fetchWithMetadata(ctx, req)
+	s.audit(ctx, "FetchWithMetadata", req, err)
+	return val, metadata, err
+}
+
+// fetchWithMetadata fetches a URL from a confidant along with metadata, without auditing.
+func (s *Service) fetchWithMetadata(ctx context.Context, req string) ([]byte, *majordomo.Metadata, error) {
+	// We short-circuit anything that isn't a URL as a direct value.
+	if req == "" {
+		// Empty req is never found.
+		return nil, nil, majordomo.ErrNotFound
+	}
+	literal, err := s.isLiteral(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if literal {
+		return []byte(req), &majordomo.Metadata{}, nil
+	}
+
+	url, route, err := s.authorizedRoute(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	processing, err := splitProcessing(url)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	started := time.Now()
+	var val []byte
+	metadata := &majordomo.Metadata{}
+	if metadataFetcher, isMetadataFetcher := route.confidant.(majordomo.MetadataFetcher); isMetadataFetcher {
+		val, metadata, err = metadataFetcher.FetchWithMetadata(ctx, url)
+	} else {
+		val, err = route.confidant.Fetch(ctx, url)
+	}
+	if err == nil && processing.required() {
+		val, err = s.process(ctx, url, processing, val)
+	}
+	s.recordFetch(route, started, err)
+	if err != nil {
+		return nil, nil, confidantError(url, err)
+	}
+	return val, metadata, nil
+}
+
+// FetchStream fetches a URL from a confidant, returning a reader for the value.
+// The reader returns majordomo.ErrValueTooLarge if the value is larger than
+// the service's maximum stream size.
+// If the confidant does not implement majordomo.StreamFetcher, or a field is
+// selected or transforms applied as per Fetch(), the value is fetched in full
+// and the reader wraps it.
+// The caller must close the reader.
+func (s *Service) FetchStream(ctx context.Context, req string) (io.ReadCloser, error) {
+	reader, err := s.fetchStream(ctx, req)
+	s.audit(ctx, "FetchStream", req, err)
+	return reader, err
+}
+
+// fetchStream fetches a URL from a confidant as a reader, without auditing.
+func (s *Service) fetchStream(ctx context.Context, req string) (io.ReadCloser, error) {
+	if strings.Contains(req, "://") {
+		url, route, err := s.authorizedRoute(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		if streamFetcher, isStreamFetcher := route.confidant.(majordomo.StreamFetcher); isStreamFetcher && !hasProcessing(url) {
+			started := time.Now()
+			reader, err := streamFetcher.FetchStream(ctx, url, s.maxStreamSize)
+			s.recordFetch(route, started, err)
+			if err != nil {
+				return nil, confidantError(url, err)
+			}
+			return reader, nil
+		}
+	}
+
+	val, err := s.fetch(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if s.maxStreamSize > 0 && int64(len(val)) > s.maxStreamSize {
+		return nil, majordomo.ErrValueTooLarge
+	}
+	return ioutil.NopCloser(bytes.NewReader(val)), nil
+}
+
+// Watch watches a value for changes.
+// An event containing the current value is sent immediately, followed by an
+// event each time the value changes.  The returned channel is closed when the
+// context is cancelled.
+// If the confidant does not implement majordomo.Watcher the value is polled
+// at the service's watch interval.
+// Fields are selected and transforms applied as per Fetch(), in which case
+// events are only sent when the resultant value changes.
+// The values of events must not be modified, as they can be retained to
+// detect changes.
+func (s *Service) Watch(ctx context.Context, req string) (<-chan *majordomo.WatchEvent, error) {
+	literal, err := s.isLiteral(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if !literal {
+		url, route, err := s.authorizedRoute(ctx, req)
+		if err != nil {
+			s.audit(ctx, "Watch", req, err)
+			return nil, err
+		}
+		if watcher, isWatcher := route.confidant.(majordomo.Watcher); isWatcher {
+			processing, err := splitProcessing(url)
+			if err != nil {
+				s.audit(ctx, "Watch", req, err)
+				return nil, err
+			}
+			ch, err := watcher.Watch(ctx, url)
+			if err != nil {
+				err = confidantError(url, err)
+				s.audit(ctx, "Watch", req, err)
+				return nil, err
+			}
+			ch = s.recordEvents(ctx, req, url, route, ch)
+			if processing.required() {
+				return s.processEvents(ctx, url, processing, ch), nil
+			}
+			return ch, nil
+		}
+	}
+
+	return poll.Watch(ctx, s.watchInterval, func(ctx context.Context) ([]byte, error) {
+		val, err := s.fetch(ctx, req)
+		s.audit(ctx, "Watch", req, err)
+		return val, err
+	}), nil
+}
+
+// recordEvents records metrics and audit events for each event sent by a
+// confidant that watches values natively, as the polling watch does for
+// each fetch.  Events are passed on unchanged.
+func (s *Service) recordEvents(ctx context.Context, req string, url *url.URL, route *route, ch <-chan *majordomo.WatchEvent) <-chan *majordomo.WatchEvent {
+	if s.metrics == nil && s.auditSink == nil {
+		return ch
+	}
+
+	res := make(chan *majordomo.WatchEvent, 1)
+	go func() {
+		defer close(res)
+		for event := range ch {
+			// The value is sent by the confidant rather than fetched, so the
+			// fetch takes no time.
+			err := confidantError(url, event.Err)
+			s.recordFetch(route, time.Now(), err)
+			s.audit(ctx, "Watch", req, err)
+			select {
+			case res <- event:
+			case <-ctx.Done():
+				// Drain the source channel so that the watcher can exit.
+				for range ch {
+				}
+				return
+			}
+		}
+	}()
+
+	return res
+}
+
+// Store stores a value in a confidant, overwriting any existing value.
+// The confidant that handles the URL's scheme must implement majordomo.WritableConfidant.
+func (s *Service) Store(ctx context.Context, req string, value []byte) error {
+	url, confidant, err := s.resolve(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	if hasProcessing(url) {
+		// Cannot operate on individual fields or transformed values.
+		return majordomo.ErrURLInvalid
+	}
+
+	writableConfidant, isWritable := confidant.(majordomo.WritableConfidant)
+	if !isWritable {
+		return majordomo.ErrNotSupported
+	}
+
+	return confidantError(url, writableConfidant.Store(ctx, url, value))
+}
+
+// Delete deletes a value from a confidant.
+// The confidant that handles the URL's scheme must implement majordomo.WritableConfidant.
+func (s *Service) Delete(ctx context.Context, req string) error {
+	url, confidant, err := s.resolve(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	if hasProcessing(url) {
+		// Cannot operate on individual fields or transformed values.
+		return majordomo.ErrURLInvalid
+	}
+
+	writableConfidant, isWritable := confidant.(majordomo.WritableConfidant)
+	if !isWritable {
+		return majordomo.ErrNotSupported
+	}
+
+	return confidantError(url, writableConfidant.Delete(ctx, url))
+}
+
+// List lists all keys that match the prefix, across all pages.
+// The confidant that handles the prefix's scheme must implement majordomo.Lister.
+func (s *Service) List(ctx context.Context, prefix string) ([]string, error) {
+	keys := make([]string, 0)
+	pageToken := ""
+	for {
+		pageKeys, nextPageToken, err := s.ListPage(ctx, prefix, pageToken, 0)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, pageKeys...)
+		if nextPageToken == "" {
+			break
+		}
+		pageToken = nextPageToken
+	}
+
+	return keys, nil
+}
+
+// ListPage lists a single page of keys that match the prefix.
+// The confidant that handles the prefix's scheme must implement majordomo.Lister.
+// If the service has a policy, keys that the caller is not allowed to access are
+// omitted so pages can contain fewer keys than requested.
+func (s *Service) ListPage(ctx context.Context, prefix string, pageToken string, pageSize int) ([]string, string, error) {
+	url, route, err := s.resolveRoute(prefix)
+	if err != nil {
+		return nil, "", err
+	}
+	if hasProcessing(url) {
+		return nil, "", majordomo.ErrURLInvalid
+	}
+
+	lister, isLister := route.confidant.(majordomo.Lister)
+	if !isLister {
+		return nil, "", majordomo.ErrNotSupported
+	}
+
+	keys, nextPageToken, err := lister.List(ctx, url, pageToken, pageSize)
+	if err != nil {
+		return nil, "", confidantError(url, err)
+	}
+	// Keys are returned with the confidant's scheme, so convert them back to the route's scheme.
+	// Keys that the caller is not allowed to access are omitted.
+	allowedKeys := make([]string, 0, len(keys))
+	for i := range keys {
+		key := route.externalKey(keys[i])
+		if s.authorize(ctx, key) == nil {
+			allowedKeys = append(allowedKeys, key)
+		}
+	}
+	return allowedKeys, nextPageToken, nil
+}
+
+// ListAllError is the error returned by ListAll() when the keys for some
+// routes could not be listed.
+type ListAllError struct {
+	// Errors are the errors encountered, keyed by route as per Health().
+	Errors map[string]error
+}
+
+// Error returns the error message, listing the routes that failed in order.
+func (e *ListAllError) Error() string {
+	routes := make([]string, 0, len(e.Errors))
+	for route := range e.Errors {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	msgs := make([]string, len(routes))
+	for i, route := range routes {
+		msgs[i] = fmt.Sprintf("%s: %v", route, e.Errors[route])
+	}
+	return fmt.Sprintf("failed to list keys for %d route(s): %s", len(routes), strings.Join(msgs, "; "))
+}
+
+// ListAll lists all keys held by all registered confidants that implement majordomo.Lister.
+// Each route is listed from its root, for example "gsm:///" or for a route
+// with a prefix "gsm://prod-project/", so confidants that require a location
+// such as a region or project must have a default configured.  Keys that are
+// handled by a more specific route are only returned for that route.
+// If some routes cannot be listed the keys for the other routes are returned
+// along with a *ListAllError containing the error for each failed route.
+func (s *Service) ListAll(ctx context.Context) ([]string, error) {
+	routes := s.listerRoutes()
+
+	keys := make([]string, 0)
+	failures := make(map[string]error)
+	for _, route := range routes {
+		root := fmt.Sprintf("%s:///", route.scheme)
+		if route.prefix != "" {
+			root = fmt.Sprintf("%s://%s", route.scheme, route.prefix)
+		}
+		routeKeys, err := s.List(ctx, root)
+		if err != nil {
+			log.Debug().Str("route", route.String()).Err(err).Msg("Failed to list keys")
+			failures[route.String()] = err
+			continue
+		}
+		for _, key := range routeKeys {
+			if _, keyRoute, err := s.resolveRoute(key); err == nil && keyRoute != route {
+				continue
+			}
+			keys = append(keys, key)
+		}
+	}
+
+	if len(failures) > 0 {
+		return keys, &ListAllError{Errors: failures}
+	}
+	return keys, nil
+}
+
+// listerRoutes returns the routes whose confidants implement majordomo.Lister, ordered by name.
+func (s *Service) listerRoutes() []*route {
+	s.routesMu.RLock()
+	routes := make([]*route, 0)
+	for _, schemeRoutes := range s.routes {
+		for _, route := range schemeRoutes {
+			if _, isLister := route.confidant.(majordomo.Lister); isLister {
+				routes = append(routes, route)
+			}
+		}
+	}
+	s.routesMu.RUnlock()
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].String() < routes[j].String()
+	})
+
+	return routes
+}
+
+// Health checks the health of the registered confidants.
+// The result contains an entry for each registered route, keyed by its scheme
+// or, for routes with a prefix, by "scheme://prefix".  The entry is nil if
+// the confidant for that route is healthy or an error describing the problem
+// if not.  Confidants that do not implement majordomo.Pinger are assumed to be healthy.
+func (s *Service) Health(ctx context.Context) map[string]error {
+	// Ping each confidant once, even if it handles multiple routes.
+	pingers := make(map[majordomo.Pinger][]string)
+	res := make(map[string]error)
+	s.routesMu.RLock()
+	for _, routes := range s.routes {
+		for _, route := range routes {
+			if pinger, isPinger := route.confidant.(majordomo.Pinger); isPinger {
+				pingers[pinger] = append(pingers[pinger], route.String())
+			} else {
+				res[route.String()] = nil
+			}
+		}
+	}
+	s.routesMu.RUnlock()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for pinger, schemes := range pingers {
+		wg.Add(1)
+		go func(pinger majordomo.Pinger, schemes []string) {
+			defer wg.Done()
+			err := pinger.Ping(ctx)
+			if err != nil {
+				log.Debug().Strs("schemes", schemes).Err(err).Msg("Confidant is unhealthy")
+			}
+			mu.Lock()
+			for _, scheme := range schemes {
+				res[scheme] = err
+			}
+			mu.Unlock()
+		}(pinger, schemes)
+	}
+	wg.Wait()
+
+	return res
+}
+
+// isLiteral returns true if the request is a literal value rather than a
+// reference to a value held by a confidant.  An error is returned if the
+// request is a literal but the service is in strict mode or the context
+// requires a reference.
+func (s *Service) isLiteral(ctx context.Context, req string) (bool, error) {
+	if strings.Contains(req, "://") {
+		return false, nil
+	}
+	if referenceRequired, _ := ctx.Value(&ReferenceRequired{}).(bool); s.strict || referenceRequired {
+		return false, majordomo.ErrNotReference
+	}
+
+	return true, nil
+}
+
+// resolve parses a request as a URL and obtains the confidant that handles it,
+// checking that the caller is allowed to access it.
+// The returned URL is that which should be passed to the confidant.
+func (s *Service) resolve(ctx context.Context, req string) (*url.URL, majordomo.Confidant, error) {
+	url, route, err := s.authorizedRoute(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return url, route.confidant, nil
+}
+
+// authorizedRoute parses a request as a URL and obtains the route that handles
+// it, as per resolveRoute(), checking that the caller is allowed to access it.
+func (s *Service) authorizedRoute(ctx context.Context, req string) (*url.URL, *route, error) {
+	url, route, err := s.resolveRoute(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.authorize(ctx, req); err != nil {
+		return nil, nil, err
+	}
+
+	return url, route, nil
+}
+
+// authorize checks that the caller is allowed to access a request by the
+// service's policy.  The request is checked as supplied, before aliases are
+// resolved.
+func (s *Service) authorize(ctx context.Context, req string) error {
+	if s.policy == nil {
+		return nil
+	}
+	url, err := url.Parse(req)
+	if err != nil {
+		return majordomo.ErrURLInvalid
+	}
+	if !s.policy.Allowed(policy.ContextIdentity(ctx), url) {
+		log.Debug().Str("scheme", url.Scheme).Msg("Access denied by policy")
+		return confidantError(url, majordomo.NewError(majordomo.ErrPermissionDenied, policy.ErrDenied))
+	}
+
+	return nil
+}
+
+// resolveRoute parses a request as a URL and obtains the route that handles it.
+// The returned URL is that which should be passed to the route's confidant.
+func (s *Service) resolveRoute(req string) (*url.URL, *route, error) {
+	if req == "" {
+		return nil, nil, majordomo.ErrURLInvalid
+	}
 	url, err := url.Parse(req)
 	if err != nil {
-		return nil, majordomo.ErrURLInvalid
+		return nil, nil, majordomo.ErrURLInvalid
 	}
 	if url.Scheme == "" {
-		return nil, majordomo.ErrURLInvalid
+		return nil, nil, majordomo.ErrURLInvalid
 	}
 
-	confidant, exists := s.confidants[url.Scheme]
+	s.routesMu.RLock()
+	routes, exists := s.routes[url.Scheme]
+	s.routesMu.RUnlock()
 	if !exists {
-		return nil, majordomo.ErrSchemeUnknown
+		return nil, nil, majordomo.ErrSchemeUnknown
 	}
 
-	val, err := confidant.Fetch(ctx, url)
-	if err != nil {
-		// We return this error without wrapping it to allow comparison to majordomo well-known errors.
-		return nil, err
+	// Routes are ordered by decreasing prefix length, so the first match is the most specific.
+	for _, route := range routes {
+		if route.matches(url) {
+			url.Scheme = route.target
+			return url, route, nil
+		}
 	}
-	return val, nil
+
+	return nil, nil, majordomo.ErrSchemeUnknown
+}
+
+// recordFetch records the completion of a fetch from a route's confidant.
+// The route's scheme is used as the label rather than that of the request, so
+// that only registered schemes are reported.
+func (s *Service) recordFetch(route *route, started time.Time, err error) {
+	if s.metrics == nil {
+		return
+	}
+	s.metrics.FetchCompleted(route.scheme, metrics.OutcomeOf(err), time.Since(started))
+}
+
+// audit records an audit event for an attempt to fetch a value.
+// Keys that are not references, or are handled by the direct confidant, are
+// not recorded as they are not held by a confidant.  Failure to record the event is logged but does not fail the fetch.
+func (s *Service) audit(ctx context.Context, operation string, req string, err error) {
+	if s.auditSink == nil || !strings.Contains(req, "://") {
+		return
+	}
+	if _, route, err := s.resolveRoute(req); err == nil && route.target == "direct" {
+		// Keys for the direct confidant hold their values, so are literals.
+		return
+	}
+
+	event := &audit.Event{
+		Time:       time.Now(),
+		Operation:  operation,
+		Key:        audit.FormatKey(s.auditKeyFormat, req),
+		Outcome:    string(metrics.OutcomeOf(err)),
+		PID:        os.Getpid(),
+		Attributes: audit.ContextAttributes(ctx),
+	}
+	if url, err := url.Parse(req); err == nil {
+		event.Scheme = url.Scheme
+	}
+	if err := s.auditSink.Record(ctx, event); err != nil {
+		log.Warn().Err(err).Str("operation", operation).Msg("Failed to record audit event")
+	}
+}
+
+// confidantName returns the name of a confidant, which is the name of the
+// package that implements it, for example "gsm".  This matches the name that
+// the confidants in this module give themselves in their spans.
+func confidantName(confidant majordomo.Confidant) string {
+	name := strings.TrimPrefix(fmt.Sprintf("%T", confidant), "*")
+	if i := strings.Index(name, "."); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// reportConfidants reports the number of confidants registered for each scheme.
+// The caller must hold the routes lock.
+func (s *Service) reportConfidants() {
+	if s.metrics == nil {
+		return
+	}
+	counts := make(map[string]int, len(s.routes))
+	for scheme, routes := range s.routes {
+		counts[scheme] = len(routes)
+	}
+	s.metrics.ConfidantsRegistered(counts)
+}
+
+// wellKnownErrors are the majordomo well-known errors.
+var wellKnownErrors = []error{
+	majordomo.ErrNotFound,
+	majordomo.ErrURLInvalid,
+	majordomo.ErrSchemeUnknown,
+	majordomo.ErrNotSupported,
+	majordomo.ErrValueTooLarge,
+	majordomo.ErrPermissionDenied,
+	majordomo.ErrUnavailable,
+	majordomo.ErrTimeout,
+	majordomo.ErrInvalidValue,
+}
+
+// confidantError wraps an error returned by a confidant with information about
+// the request.  The result can be compared to majordomo well-known errors with
+// errors.Is().
+func confidantError(url *url.URL, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	res := &majordomo.Error{
+		Scheme: url.Scheme,
+		Key:    redact.URL(url),
+	}
+	if confidantErr, isError := err.(*majordomo.Error); isError {
+		res.Kind = confidantErr.Kind
+		res.Err = confidantErr.Err
+		return res
+	}
+	for _, wellKnownErr := range wellKnownErrors {
+		if err == wellKnownErr {
+			res.Kind = err
+			return res
+		}
+	}
+	res.Err = err
+	if errors.Is(err, context.DeadlineExceeded) {
+		res.Kind = majordomo.ErrTimeout
+	}
+
+	return res
 }
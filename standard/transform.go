@@ -0,0 +1,238 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package standard
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	majordomo "github.com/wealdtech/go-majordomo"
+	"github.com/wealdtech/go-majordomo/internal/memory"
+	"github.com/wealdtech/go-majordomo/internal/poll"
+)
+
+// transformParam is the query parameter that declares the transforms for a value.
+const transformParam = "transform"
+
+// transform transforms a value.  The returned value must not share memory
+// with the supplied value, as the supplied value is zeroed once transformed.
+type transform func(data []byte, maxSize int64) ([]byte, error)
+
+// transforms are the available transforms.
+var transforms = map[string]transform{
+	"trim":            trimTransform,
+	"base64decode":    base64DecodeTransform(base64.StdEncoding),
+	"base64urldecode": base64DecodeTransform(base64.URLEncoding),
+	"hexdecode":       hexDecodeTransform,
+	"gunzip":          gunzipTransform,
+}
+
+// trimTransform removes leading and trailing whitespace.
+func trimTransform(data []byte, _ int64) ([]byte, error) {
+	trimmed := bytes.TrimSpace(data)
+	res := make([]byte, len(trimmed))
+	copy(res, trimmed)
+	return res, nil
+}
+
+// base64DecodeTransform decodes base64 with the given encoding.
+// Values without padding are also accepted.
+func base64DecodeTransform(encoding *base64.Encoding) transform {
+	rawEncoding := encoding.WithPadding(base64.NoPadding)
+	return func(data []byte, _ int64) ([]byte, error) {
+		dataEncoding := encoding
+		if len(data)%4 != 0 {
+			dataEncoding = rawEncoding
+		}
+		res := make([]byte, dataEncoding.DecodedLen(len(data)))
+		n, err := dataEncoding.Decode(res, data)
+		if err != nil {
+			memory.Zero(res)
+			return nil, errors.Wrap(err, "invalid base64")
+		}
+		return res[:n], nil
+	}
+}
+
+// hexDecodeTransform decodes hex, with or without a leading "0x".
+func hexDecodeTransform(data []byte, _ int64) ([]byte, error) {
+	data = bytes.TrimPrefix(data, []byte("0x"))
+	res := make([]byte, hex.DecodedLen(len(data)))
+	if _, err := hex.Decode(res, data); err != nil {
+		memory.Zero(res)
+		return nil, errors.Wrap(err, "invalid hex")
+	}
+	return res, nil
+}
+
+// gunzipTransform decompresses gzipped data.
+// Decompressed data larger than the maximum size is rejected.
+func gunzipTransform(data []byte, maxSize int64) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid gzip")
+	}
+	defer reader.Close()
+
+	var buf bytes.Buffer
+	src := io.Reader(reader)
+	if maxSize > 0 {
+		// Read an additional byte to detect oversized data.
+		src = io.LimitReader(reader, maxSize+1)
+	}
+	if _, err := io.Copy(&buf, src); err != nil {
+		memory.Zero(buf.Bytes())
+		return nil, errors.Wrap(err, "invalid gzip")
+	}
+	res := buf.Bytes()
+	if maxSize > 0 && int64(len(res)) > maxSize {
+		memory.Zero(res)
+		return nil, majordomo.ErrValueTooLarge
+	}
+
+	return res, nil
+}
+
+// processing is the processing applied to a value returned by a confidant.
+type processing struct {
+	// selector selects a field from the value.
+	selector string
+	// transforms are the names of the transforms applied to the value.
+	transforms []string
+}
+
+// required returns true if the value requires processing.
+func (p *processing) required() bool {
+	return p.selector != "" || len(p.transforms) > 0
+}
+
+// hasProcessing returns true if the URL declares processing of its value.
+func hasProcessing(url *url.URL) bool {
+	_, hasTransforms := url.Query()[transformParam]
+	return url.Fragment != "" || hasTransforms
+}
+
+// splitProcessing removes the declared processing from the URL, returning it.
+// Other query parameters are left in their original order, as they may be
+// significant to the confidant.
+// majordomo.ErrURLInvalid is returned if any of the transforms are unknown.
+func splitProcessing(url *url.URL) (*processing, error) {
+	res := &processing{
+		selector: url.Fragment,
+	}
+	url.Fragment = ""
+
+	if url.RawQuery == "" {
+		return res, nil
+	}
+	params := strings.Split(url.RawQuery, "&")
+	retained := make([]string, 0, len(params))
+	for _, param := range params {
+		key, value := param, ""
+		if i := strings.Index(param, "="); i >= 0 {
+			key, value = param[:i], param[i+1:]
+		}
+		if unescapedKey, err := unescapeQuery(key); err != nil || unescapedKey != transformParam {
+			retained = append(retained, param)
+			continue
+		}
+		value, err := unescapeQuery(value)
+		if err != nil {
+			return nil, majordomo.ErrURLInvalid
+		}
+		for _, name := range strings.Split(value, ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if _, exists := transforms[name]; !exists {
+				return nil, majordomo.ErrURLInvalid
+			}
+			res.transforms = append(res.transforms, name)
+		}
+	}
+	url.RawQuery = strings.Join(retained, "&")
+
+	return res, nil
+}
+
+// unescapeQuery unescapes a query key or value.
+func unescapeQuery(input string) (string, error) {
+	return url.QueryUnescape(input)
+}
+
+// process processes a value returned by a confidant.
+// The field is selected first, and the transforms applied to the result in
+// the order in which they were declared.  The supplied value is zeroed.
+func (s *Service) process(ctx context.Context, url *url.URL, p *processing, data []byte) ([]byte, error) {
+	var err error
+	if p.selector != "" {
+		data, err = selectField(ctx, url, p.selector, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range p.transforms {
+		transformed, err := transforms[name](data, s.maxStreamSize)
+		memory.Zero(data)
+		if err != nil {
+			if errors.Is(err, majordomo.ErrValueTooLarge) {
+				return nil, err
+			}
+			return nil, majordomo.NewError(majordomo.ErrInvalidValue, errors.Wrap(err, name))
+		}
+		data = transformed
+	}
+
+	return data, nil
+}
+
+// processEvents processes the values of watch events, sending an event only
+// when the processed value changes.
+func (s *Service) processEvents(ctx context.Context, url *url.URL, p *processing, ch <-chan *majordomo.WatchEvent) <-chan *majordomo.WatchEvent {
+	res := make(chan *majordomo.WatchEvent, 1)
+	go func() {
+		defer close(res)
+		var last *majordomo.WatchEvent
+		for event := range ch {
+			value, err := event.Value, event.Err
+			if err == nil {
+				// The watcher can retain the value of the event, so process a copy.
+				value, err = s.process(ctx, url, p, memory.Copy(value))
+			}
+			processed := &majordomo.WatchEvent{
+				Value: value,
+				Err:   confidantError(url, err),
+			}
+			if !poll.Changed(last, processed) {
+				continue
+			}
+			select {
+			case res <- processed:
+				last = processed
+			case <-ctx.Done():
+				// Drain the source channel so that the watcher can exit.
+				for range ch {
+				}
+				return
+			}
+		}
+	}()
+
+	return res
+}
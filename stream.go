@@ -0,0 +1,58 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package majordomo
+
+import "io"
+
+// limitedReadCloser is a reader that returns ErrValueTooLarge if the
+// underlying reader provides more than the maximum number of bytes.
+type limitedReadCloser struct {
+	rc        io.ReadCloser
+	remaining int64
+}
+
+// NewLimitedReadCloser returns a reader that reads from rc, returning
+// ErrValueTooLarge if more than maxSize bytes are available.
+// A maxSize of 0 or less means that there is no limit.
+func NewLimitedReadCloser(rc io.ReadCloser, maxSize int64) io.ReadCloser {
+	if maxSize <= 0 {
+		return rc
+	}
+	return &limitedReadCloser{
+		rc:        rc,
+		remaining: maxSize,
+	}
+}
+
+// Read reads from the underlying reader.
+func (l *limitedReadCloser) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, ErrValueTooLarge
+	}
+	// Allow one byte more than the limit to be read, to detect oversized values.
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.rc.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n + int(l.remaining), ErrValueTooLarge
+	}
+	return n, err
+}
+
+// Close closes the underlying reader.
+func (l *limitedReadCloser) Close() error {
+	return l.rc.Close()
+}
@@ -0,0 +1,104 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package template
+
+import (
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	majordomo "github.com/wealdtech/go-majordomo"
+)
+
+// MissingKeyPolicy is the policy for references whose keys are not found.
+type MissingKeyPolicy int
+
+const (
+	// MissingKeyError returns an error if a key is not found.
+	MissingKeyError MissingKeyPolicy = iota
+	// MissingKeyEmpty replaces the reference with an empty value if its key is not found.
+	MissingKeyEmpty
+	// MissingKeyKeep leaves the reference in place if its key is not found.
+	MissingKeyKeep
+)
+
+type parameters struct {
+	logLevel         zerolog.Level
+	majordomo        majordomo.Service
+	missingKeyPolicy MissingKeyPolicy
+	parallelism      int
+}
+
+// Parameter is the interface for service parameters.
+type Parameter interface {
+	apply(*parameters)
+}
+
+type parameterFunc func(*parameters)
+
+func (f parameterFunc) apply(p *parameters) {
+	f(p)
+}
+
+// WithLogLevel sets the log level for the module.
+func WithLogLevel(logLevel zerolog.Level) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.logLevel = logLevel
+	})
+}
+
+// WithMajordomo sets the majordomo service used to resolve references.
+func WithMajordomo(service majordomo.Service) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.majordomo = service
+	})
+}
+
+// WithMissingKeyPolicy sets the policy for references whose keys are not found.
+func WithMissingKeyPolicy(policy MissingKeyPolicy) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.missingKeyPolicy = policy
+	})
+}
+
+// WithParallelism sets the maximum number of concurrent fetches.
+func WithParallelism(parallelism int) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.parallelism = parallelism
+	})
+}
+
+// parseAndCheckParameters parses and checks parameters to ensure that mandatory parameters are present and correct.
+func parseAndCheckParameters(params ...Parameter) (*parameters, error) {
+	parameters := parameters{
+		logLevel:         zerolog.GlobalLevel(),
+		missingKeyPolicy: MissingKeyError,
+		parallelism:      16,
+	}
+	for _, p := range params {
+		if params != nil {
+			p.apply(&parameters)
+		}
+	}
+
+	if parameters.majordomo == nil {
+		return nil, errors.New("no majordomo specified")
+	}
+	if parameters.missingKeyPolicy < MissingKeyError || parameters.missingKeyPolicy > MissingKeyKeep {
+		return nil, errors.New("invalid missing key policy")
+	}
+	if parameters.parallelism <= 0 {
+		return nil, errors.New("parallelism must be greater than 0")
+	}
+
+	return &parameters, nil
+}
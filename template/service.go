@@ -0,0 +1,244 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package template
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	zerologger "github.com/rs/zerolog/log"
+	majordomo "github.com/wealdtech/go-majordomo"
+	"github.com/wealdtech/go-majordomo/internal/memory"
+)
+
+// Service expands majordomo references in text.
+// A reference is of the form "${majordomo:<key>}", for example
+// "${majordomo:asm:///db-password}", and is replaced by the value of the key
+// as fetched from the majordomo service.  Keys cannot contain "}".
+// A reference can be escaped by doubling the leading "$", so
+// "$${majordomo:asm:///db-password}" is output as
+// "${majordomo:asm:///db-password}".  More generally each "$$" in a run of
+// "$" that leads a reference is output as "$", and the reference is expanded
+// if a single "$" remains, so "$$${majordomo:asm:///db-password}" is output as
+// "$" followed by the value.
+// All references in the text are fetched concurrently, and each distinct key
+// is fetched once.
+type Service struct {
+	majordomo        majordomo.Service
+	missingKeyPolicy MissingKeyPolicy
+	parallelism      int
+}
+
+// module-wide log.
+var log zerolog.Logger
+
+// referencePrefix is the prefix of a reference.
+var referencePrefix = []byte("${majordomo:")
+
+// New creates a new template service.
+func New(ctx context.Context, params ...Parameter) (*Service, error) {
+	parameters, err := parseAndCheckParameters(params...)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with parameters")
+	}
+
+	// Set logging.
+	log = zerologger.With().Str("service", "template").Str("impl", "standard").Logger()
+	if parameters.logLevel != log.GetLevel() {
+		log = log.Level(parameters.logLevel)
+	}
+
+	s := &Service{
+		majordomo:        parameters.majordomo,
+		missingKeyPolicy: parameters.missingKeyPolicy,
+		parallelism:      parameters.parallelism,
+	}
+
+	return s, nil
+}
+
+// segment is part of a template: either literal text or a reference.
+type segment struct {
+	// text is the literal text, if this is not a reference.
+	text []byte
+	// key is the key of the reference, if this is a reference.
+	key string
+	// line is the line on which the reference starts.
+	line int
+}
+
+// Expand expands the references in the input.
+// The input is not altered.
+func (s *Service) Expand(ctx context.Context, input []byte) ([]byte, error) {
+	segments, err := parse(input)
+	if err != nil {
+		return nil, err
+	}
+
+	values, errs := s.fetch(ctx, segments)
+	// Values have been copied to the output by the time we return, so zero them.
+	defer func() {
+		for _, value := range values {
+			memory.Zero(value)
+		}
+	}()
+
+	var output bytes.Buffer
+	for _, segment := range segments {
+		if segment.key == "" {
+			output.Write(segment.text)
+			continue
+		}
+		if err := errs[segment.key]; err != nil {
+			if !errors.Is(err, majordomo.ErrNotFound) || s.missingKeyPolicy == MissingKeyError {
+				memory.Zero(output.Bytes())
+				// The key is not included in the error as it could contain credentials.
+				return nil, errors.Wrap(err, fmt.Sprintf("failed to expand reference on line %d", segment.line))
+			}
+			if s.missingKeyPolicy == MissingKeyKeep {
+				output.Write(referencePrefix)
+				output.WriteString(segment.key)
+				output.WriteByte('}')
+			}
+			continue
+		}
+		output.Write(values[segment.key])
+	}
+
+	return output.Bytes(), nil
+}
+
+// ExpandString expands the references in the input.
+func (s *Service) ExpandString(ctx context.Context, input string) (string, error) {
+	output, err := s.Expand(ctx, []byte(input))
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
+
+// ExpandReader expands the references in the data read from the reader.
+func (s *Service) ExpandReader(ctx context.Context, reader io.Reader) ([]byte, error) {
+	input, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read input")
+	}
+	return s.Expand(ctx, input)
+}
+
+// parse parses the input in to literal and reference segments.
+func parse(input []byte) ([]*segment, error) {
+	segments := make([]*segment, 0)
+	literal := make([]byte, 0, len(input))
+	line := 1
+	for len(input) > 0 {
+		index := bytes.Index(input, referencePrefix)
+		if index == -1 {
+			literal = append(literal, input...)
+			break
+		}
+		line += bytes.Count(input[:index], []byte("\n"))
+
+		// Find the run of "$" that leads the reference, each pair of which is
+		// an escaped "$".
+		start := index
+		for start > 0 && input[start-1] == '$' {
+			start--
+		}
+		dollars := index - start + 1
+		literal = append(literal, input[:start]...)
+		literal = append(literal, bytes.Repeat([]byte("$"), dollars/2)...)
+		if dollars%2 == 0 {
+			// Escaped reference; output the rest of the prefix as-is.
+			literal = append(literal, referencePrefix[1:]...)
+			input = input[index+len(referencePrefix):]
+			continue
+		}
+
+		input = input[index+len(referencePrefix):]
+		end := bytes.IndexByte(input, '}')
+		if end == -1 {
+			return nil, fmt.Errorf("unterminated reference on line %d", line)
+		}
+		key := input[:end]
+		if len(key) == 0 {
+			return nil, fmt.Errorf("empty reference on line %d", line)
+		}
+		if bytes.IndexByte(key, '\n') != -1 {
+			return nil, fmt.Errorf("unterminated reference on line %d", line)
+		}
+
+		if len(literal) > 0 {
+			segments = append(segments, &segment{text: literal})
+			literal = make([]byte, 0, len(input))
+		}
+		segments = append(segments, &segment{
+			key:  string(key),
+			line: line,
+		})
+		input = input[end+1:]
+	}
+	if len(literal) > 0 {
+		segments = append(segments, &segment{text: literal})
+	}
+
+	return segments, nil
+}
+
+// fetch fetches the values for the references in the segments concurrently.
+// Each distinct key is fetched once.
+func (s *Service) fetch(ctx context.Context, segments []*segment) (map[string][]byte, map[string]error) {
+	keys := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, segment := range segments {
+		if segment.key != "" && !seen[segment.key] {
+			seen[segment.key] = true
+			keys = append(keys, segment.key)
+		}
+	}
+
+	values := make(map[string][]byte, len(keys))
+	errs := make(map[string]error)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, s.parallelism)
+	for _, key := range keys {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(key string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			value, err := s.majordomo.Fetch(ctx, key)
+			if err != nil {
+				log.Debug().Err(err).Msg("Failed to fetch reference")
+			}
+			mu.Lock()
+			if err != nil {
+				errs[key] = err
+			} else {
+				values[key] = value
+			}
+			mu.Unlock()
+		}(key)
+	}
+	wg.Wait()
+
+	return values, errs
+}
@@ -0,0 +1,213 @@
+// Copyright © 2026 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package template_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	majordomo "github.com/wealdtech/go-majordomo"
+	"github.com/wealdtech/go-majordomo/template"
+)
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := template.New(ctx, template.WithLogLevel(zerolog.Disabled))
+	require.EqualError(t, err, "problem with parameters: no majordomo specified")
+
+	_, err = template.New(ctx, template.WithLogLevel(zerolog.Disabled), template.WithMajordomo(&MockMajordomo{}), template.WithParallelism(0))
+	require.EqualError(t, err, "problem with parameters: parallelism must be greater than 0")
+
+	_, err = template.New(ctx, template.WithLogLevel(zerolog.Disabled), template.WithMajordomo(&MockMajordomo{}), template.WithMissingKeyPolicy(template.MissingKeyPolicy(99)))
+	require.EqualError(t, err, "problem with parameters: invalid missing key policy")
+}
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		policy template.MissingKeyPolicy
+		output string
+		err    string
+	}{
+		{
+			name:   "Empty",
+			input:  "",
+			output: "",
+		},
+		{
+			name:   "NoReferences",
+			input:  "username: user\n",
+			output: "username: user\n",
+		},
+		{
+			name:   "Single",
+			input:  "password: ${majordomo:mock:///password}\n",
+			output: "password: secret\n",
+		},
+		{
+			name:   "Multiple",
+			input:  "${majordomo:mock:///username}:${majordomo:mock:///password}@${majordomo:mock:///password}",
+			output: "user:secret@secret",
+		},
+		{
+			name:   "Escaped",
+			input:  "literal: $${majordomo:mock:///password}",
+			output: "literal: ${majordomo:mock:///password}",
+		},
+		{
+			name:   "EscapedDollar",
+			input:  "price: $$${majordomo:mock:///password}",
+			output: "price: $secret",
+		},
+		{
+			name:   "EscapedDollarEscaped",
+			input:  "literal: $$$${majordomo:mock:///password}",
+			output: "literal: $${majordomo:mock:///password}",
+		},
+		{
+			name:   "EscapedAfterReference",
+			input:  "${majordomo:mock:///username}$${majordomo:mock:///password}",
+			output: "user${majordomo:mock:///password}",
+		},
+		{
+			name:   "OtherDollars",
+			input:  "cost: $5 ${HOME} $${other}",
+			output: "cost: $5 ${HOME} $${other}",
+		},
+		{
+			name:  "Unterminated",
+			input: "line 1\npassword: ${majordomo:mock:///password\n",
+			err:   "unterminated reference on line 2",
+		},
+		{
+			name:  "EmptyKey",
+			input: "password: ${majordomo:}",
+			err:   "empty reference on line 1",
+		},
+		{
+			name:  "MissingError",
+			input: "line 1\nline 2\npassword: ${majordomo:mock:///missing}\n",
+			err:   "failed to expand reference on line 3: key not known",
+		},
+		{
+			name:   "MissingEmpty",
+			input:  "password: ${majordomo:mock:///missing}\n",
+			policy: template.MissingKeyEmpty,
+			output: "password: \n",
+		},
+		{
+			name:   "MissingKeep",
+			input:  "password: ${majordomo:mock:///missing}\n",
+			policy: template.MissingKeyKeep,
+			output: "password: ${majordomo:mock:///missing}\n",
+		},
+		{
+			name:   "FailureNotMissing",
+			input:  "password: ${majordomo:mock:///failure}\n",
+			policy: template.MissingKeyKeep,
+			err:    "failed to expand reference on line 1: confidant unavailable",
+		},
+	}
+
+	ctx := context.Background()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service, err := template.New(ctx,
+				template.WithLogLevel(zerolog.Disabled),
+				template.WithMajordomo(&MockMajordomo{}),
+				template.WithMissingKeyPolicy(test.policy),
+			)
+			require.NoError(t, err)
+			output, err := service.ExpandString(ctx, test.input)
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, test.output, output)
+			}
+		})
+	}
+}
+
+func TestExpandReader(t *testing.T) {
+	ctx := context.Background()
+	service, err := template.New(ctx, template.WithLogLevel(zerolog.Disabled), template.WithMajordomo(&MockMajordomo{}))
+	require.NoError(t, err)
+
+	output, err := service.ExpandReader(ctx, strings.NewReader("DB_PASSWORD=${majordomo:mock:///password}\n"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("DB_PASSWORD=secret\n"), output)
+}
+
+func TestExpandConcurrent(t *testing.T) {
+	ctx := context.Background()
+	mock := &MockMajordomo{delay: 50 * time.Millisecond}
+	service, err := template.New(ctx, template.WithLogLevel(zerolog.Disabled), template.WithMajordomo(mock), template.WithParallelism(4))
+	require.NoError(t, err)
+
+	input := strings.Repeat("${majordomo:mock:///password}${majordomo:mock:///username}${majordomo:mock:///other1}${majordomo:mock:///other2}", 10)
+	_, err = service.ExpandString(ctx, input)
+	require.NoError(t, err)
+	// Each distinct key is fetched once, concurrently.
+	require.Equal(t, 4, mock.fetches)
+	require.Equal(t, 4, mock.maxConcurrent)
+}
+
+// MockMajordomo is a mock implementation of a majordomo service.
+type MockMajordomo struct {
+	delay         time.Duration
+	mu            sync.Mutex
+	fetches       int
+	concurrent    int
+	maxConcurrent int
+}
+
+// Fetch returns values based on the key.
+func (m *MockMajordomo) Fetch(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	m.fetches++
+	m.concurrent++
+	if m.concurrent > m.maxConcurrent {
+		m.maxConcurrent = m.concurrent
+	}
+	m.mu.Unlock()
+	time.Sleep(m.delay)
+	m.mu.Lock()
+	m.concurrent--
+	m.mu.Unlock()
+
+	switch key {
+	case "mock:///username":
+		return []byte("user"), nil
+	case "mock:///password":
+		return []byte("secret"), nil
+	case "mock:///missing":
+		return nil, majordomo.ErrNotFound
+	case "mock:///failure":
+		return nil, majordomo.NewError(majordomo.ErrUnavailable, nil)
+	default:
+		if strings.HasPrefix(key, "mock:///other") {
+			return []byte("other"), nil
+		}
+		return nil, errors.New("unexpected key")
+	}
+}